@@ -79,3 +79,31 @@ func BenchmarkKlogJSONEncoder10CtxFields(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkKlogTextEncoderTypedFields(b *testing.B) {
+	logger := New("").WithEncoder(TextEncoder(DiscardWriter())).WithCtx(typedFields()...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			fb := typedFieldBuilder()
+			logger.Info("message", fb.Fields()...)
+			fb.Release()
+		}
+	})
+}
+
+func BenchmarkKlogJSONEncoderTypedFields(b *testing.B) {
+	logger := New("").WithEncoder(JSONEncoder(DiscardWriter())).WithCtx(typedFields()...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			fb := typedFieldBuilder()
+			logger.Info("message", fb.Fields()...)
+			fb.Release()
+		}
+	})
+}
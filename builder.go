@@ -21,7 +21,6 @@ import (
 	"io"
 	"reflect"
 	"strconv"
-	"strings"
 	"time"
 	"unicode/utf8"
 )
@@ -30,6 +29,8 @@ import (
 // the only way to construct one is via a Pool.
 type Builder struct {
 	buf []byte
+
+	escapeHTML bool
 }
 
 // NewBuilder returns a new Builder with a initial capacity n.
@@ -326,16 +327,129 @@ func (b *Builder) AppendAnyFmt(any interface{}) error {
 	return err
 }
 
-// AppendJSONString appends a string as JSON string, which will escape
-// the double quotation(") and enclose it with a pair of the double quotation.
+// SetEscapeHTML sets whether AppendJSONString and AppendJSONBytes escape
+// the HTML characters '<', '>' and '&' as "\u003c", "\u003e" and "\u0026",
+// like encoding/json, which is false by default.
+func (b *Builder) SetEscapeHTML(on bool) { b.escapeHTML = on }
+
+// AppendJSONString appends a string as JSON string defined by RFC 8259,
+// which will escape the double quotation, the backslash and the control
+// characters, replace the invalid UTF-8 bytes with U+FFFD, and enclose it
+// with a pair of the double quotation.
+//
+// U+2028 and U+2029 are also escaped, like encoding/json.
 func (b *Builder) AppendJSONString(s string) {
-	if strings.IndexByte(s, '"') > -1 {
-		b.buf = strconv.AppendQuote(b.buf, s)
-	} else {
-		b.WriteByte('"')
-		b.WriteString(s)
-		b.WriteByte('"')
+	safeSet := &jsonSafeSet
+	if b.escapeHTML {
+		safeSet = &htmlSafeSet
+	}
+
+	b.buf = append(b.buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if safeSet[c] {
+				i++
+				continue
+			}
+
+			b.buf = append(b.buf, s[start:i]...)
+			b.buf = appendJSONEscapedByte(b.buf, c)
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError && size == 1) || r == '\u2028' || r == '\u2029' {
+			b.buf = append(b.buf, s[start:i]...)
+			b.buf = appendJSONEscapedRune(b.buf, r)
+			start = i + size
+		}
+		i += size
+	}
+	b.buf = append(b.buf, s[start:]...)
+	b.buf = append(b.buf, '"')
+}
+
+// AppendJSONBytes is the same as AppendJSONString, but appends []byte.
+func (b *Builder) AppendJSONBytes(s []byte) {
+	safeSet := &jsonSafeSet
+	if b.escapeHTML {
+		safeSet = &htmlSafeSet
+	}
+
+	b.buf = append(b.buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if safeSet[c] {
+				i++
+				continue
+			}
+
+			b.buf = append(b.buf, s[start:i]...)
+			b.buf = appendJSONEscapedByte(b.buf, c)
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRune(s[i:])
+		if (r == utf8.RuneError && size == 1) || r == '\u2028' || r == '\u2029' {
+			b.buf = append(b.buf, s[start:i]...)
+			b.buf = appendJSONEscapedRune(b.buf, r)
+			start = i + size
+		}
+		i += size
+	}
+	b.buf = append(b.buf, s[start:]...)
+	b.buf = append(b.buf, '"')
+}
+
+const hexDigits = "0123456789abcdef"
+
+// jsonSafeSet and htmlSafeSet report whether the ASCII character can be
+// written into the JSON string without escaping.
+var jsonSafeSet, htmlSafeSet [utf8.RuneSelf]bool
+
+func init() {
+	for c := 0x20; c < utf8.RuneSelf; c++ {
+		switch c {
+		case '"', '\\':
+		case '<', '>', '&':
+			jsonSafeSet[c] = true
+		default:
+			jsonSafeSet[c] = true
+			htmlSafeSet[c] = true
+		}
+	}
+}
+
+func appendJSONEscapedByte(dst []byte, c byte) []byte {
+	switch c {
+	case '"', '\\':
+		return append(dst, '\\', c)
+	case '\n':
+		return append(dst, '\\', 'n')
+	case '\r':
+		return append(dst, '\\', 'r')
+	case '\t':
+		return append(dst, '\\', 't')
+	case '\b':
+		return append(dst, '\\', 'b')
+	case '\f':
+		return append(dst, '\\', 'f')
+	default:
+		return append(dst, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xF])
+	}
+}
+
+func appendJSONEscapedRune(dst []byte, r rune) []byte {
+	if r == utf8.RuneError {
+		return append(dst, `\ufffd`...)
 	}
+	return append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
 }
 
 // AppendJSON appends the value as the JSON value, that's, the value will
@@ -0,0 +1,83 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build go1.18
+
+package klog
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func FuzzBuilder_AppendJSONString(f *testing.F) {
+	for _, s := range []string{"", "abc", `a"b\c`, "a\nb\x00\x1f\x7f",
+		"<a&b>", "中文  ", "a\xffb\xc0", "\xed\xa0\x80"} {
+		f.Add(s, false)
+		f.Add(s, true)
+	}
+
+	f.Fuzz(func(t *testing.T, s string, escapeHTML bool) {
+		b := NewBuilder(64)
+		b.SetEscapeHTML(escapeHTML)
+		b.AppendJSONString(s)
+		if !json.Valid(b.Bytes()) {
+			t.Fatalf("%q: invalid json string %s", s, b.String())
+		}
+
+		// The result must be decoded to what encoding/json encodes.
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(escapeHTML)
+		if err := enc.Encode(s); err != nil {
+			t.Fatal(err)
+		}
+
+		var expect, result string
+		if err := json.Unmarshal(buf.Bytes(), &expect); err != nil {
+			t.Fatal(err)
+		} else if err := json.Unmarshal(b.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		} else if result != expect {
+			t.Fatalf("%q: expect %q, but got %q", s, expect, result)
+		}
+
+		if escapeHTML && bytes.ContainsAny(b.Bytes(), "<>&") {
+			t.Fatalf("%q: html characters are not escaped: %s", s, b.String())
+		}
+
+		bs := NewBuilder(64)
+		bs.SetEscapeHTML(escapeHTML)
+		bs.AppendJSONBytes([]byte(s))
+		if bs.String() != b.String() {
+			t.Fatalf("%q: AppendJSONBytes got %s, but AppendJSONString got %s",
+				s, bs.String(), b.String())
+		}
+	})
+}
+
+func FuzzJSONEncoder(f *testing.F) {
+	f.Add("msg", "key", "value")
+	f.Add("a\nb", "k\"ey", "\x00\xff")
+
+	f.Fuzz(func(t *testing.T, msg, key, value string) {
+		buf := NewBuilder(128)
+		logger := New(key).WithEncoder(JSONEncoder(StreamWriter(buf), EncodeLogger("logger")))
+		logger.Info(msg, F(key, value), Str("str", value), Bytes("bytes", []byte(value)))
+		if !json.Valid(buf.Bytes()) {
+			t.Fatalf("invalid json: %s", buf.String())
+		}
+	})
+}
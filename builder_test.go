@@ -110,6 +110,41 @@ func TestBuilder_AppendJSON(t *testing.T) {
 	}
 }
 
+func TestBuilder_AppendJSONString(t *testing.T) {
+	cases := []struct{ in, out, html string }{
+		{"", `""`, `""`},
+		{"abc", `"abc"`, `"abc"`},
+		{`a"b\c`, `"a\"b\\c"`, `"a\"b\\c"`},
+		{"a\nb\r\t\b\f\x00\x1f", `"a\nb\r\t\b\f\u0000\u001f"`, `"a\nb\r\t\b\f\u0000\u001f"`},
+		{"<a&b>", `"<a&b>"`, `"\u003ca\u0026b\u003e"`},
+		{"中文\u2028\u2029", `"中文\u2028\u2029"`, `"中文\u2028\u2029"`},
+		{"a\xffb\xc0", `"a\ufffdb\ufffd"`, `"a\ufffdb\ufffd"`},
+	}
+
+	b := NewBuilder(64)
+	for _, c := range cases {
+		b.Reset()
+		b.AppendJSONString(c.in)
+		if b.String() != c.out {
+			t.Errorf("%q: expect '%s', but got '%s'", c.in, c.out, b.String())
+		}
+
+		b.Reset()
+		b.AppendJSONBytes([]byte(c.in))
+		if b.String() != c.out {
+			t.Errorf("%q: expect '%s', but got '%s'", c.in, c.out, b.String())
+		}
+
+		b.Reset()
+		b.SetEscapeHTML(true)
+		b.AppendJSONString(c.in)
+		b.SetEscapeHTML(false)
+		if b.String() != c.html {
+			t.Errorf("%q: expect '%s', but got '%s'", c.in, c.html, b.String())
+		}
+	}
+}
+
 func TestBuilder_AppendAny(t *testing.T) {
 	b := NewBuilder(64)
 	b.AppendAny([]int{1, 2, 3})
@@ -0,0 +1,101 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"path"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// CallerPathMode is the mode to trim the file path of the caller.
+type CallerPathMode uint8
+
+// Predefine some modes to trim the file path of the caller.
+const (
+	// CallerShortPath only keeps the file name, such as "logger.go:12".
+	CallerShortPath CallerPathMode = iota
+
+	// CallerPackagePath keeps the import path of the package and the file name,
+	// such as "github.com/xgfone/klog/v4/logger.go:12".
+	CallerPackagePath
+
+	// CallerFullPath keeps the full path, such as "/path/to/klog/logger.go:12".
+	CallerFullPath
+)
+
+// callerInfo is the caller resolved from the program counter.
+type callerInfo struct {
+	paths    [3]string // "file:line" indexed by CallerPathMode
+	function string    // The full function name
+}
+
+func (c *callerInfo) path(mode CallerPathMode) string {
+	if int(mode) < len(c.paths) {
+		return c.paths[mode]
+	}
+	return c.paths[CallerShortPath]
+}
+
+// callers caches the callers resolved from the program counters,
+// the number of which is bounded by that of the call sites.
+var callers = struct {
+	lock  sync.RWMutex
+	cache map[uintptr]*callerInfo
+}{cache: make(map[uintptr]*callerInfo, 64)}
+
+// lookupCaller returns the caller of the program counter returned by
+// runtime.Callers, or nil if pc is 0.
+func lookupCaller(pc uintptr) *callerInfo {
+	if pc == 0 {
+		return nil
+	}
+
+	callers.lock.RLock()
+	c, ok := callers.cache[pc]
+	callers.lock.RUnlock()
+	if ok {
+		return c
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	line := ":" + strconv.Itoa(frame.Line)
+	c = &callerInfo{function: frame.Function}
+	c.paths[CallerShortPath] = path.Base(frame.File) + line
+	c.paths[CallerFullPath] = frame.File + line
+	if pkg := funcPackage(frame.Function); pkg != "" {
+		c.paths[CallerPackagePath] = pkg + "/" + path.Base(frame.File) + line
+	} else {
+		c.paths[CallerPackagePath] = c.paths[CallerFullPath]
+	}
+
+	callers.lock.Lock()
+	callers.cache[pc] = c
+	callers.lock.Unlock()
+	return c
+}
+
+// callerPC returns the program counter of the call site, or 0.
+//
+// depth is the stack depth of the call site, and 0 identifies the caller
+// of callerPC.
+func callerPC(depth int) uintptr {
+	var pcs [1]uintptr
+	if runtime.Callers(depth+2, pcs[:]) < 1 {
+		return 0
+	}
+	return pcs[0]
+}
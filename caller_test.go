@@ -0,0 +1,67 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeCaller(t *testing.T) {
+	buf := NewBuilder(512)
+	enc := TextEncoder(StreamWriter(buf), EncodeCaller("caller"), EncodeCallerFunc("func"))
+	logger := New("").WithEncoder(enc)
+
+	wrapped := FixRecordEncoder(enc, func(r Record) Record { return r })
+	logger.Info("msg")                                         // Resolved by the depth
+	logger.WithCallerPC(true).Info("msg")                      // Resolved by the PC
+	logger.WithCallerPC(true).WithEncoder(wrapped).Info("msg") // Wrapped
+	expect := "caller=caller_test.go:28 func=github.com/xgfone/klog/v4.TestEncodeCaller msg=msg\n" +
+		"caller=caller_test.go:29 func=github.com/xgfone/klog/v4.TestEncodeCaller msg=msg\n" +
+		"caller=caller_test.go:30 func=github.com/xgfone/klog/v4.TestEncodeCaller msg=msg\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger = logger.WithCallerPC(true)
+	logger.Encoder = JSONEncoder(StreamWriter(buf), EncodeCaller("caller", CallerPackagePath))
+	logger.Info("msg")
+	expect = `{"caller":"github.com/xgfone/klog/v4/caller_test.go:41","msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Encoder = LogfmtEncoder(StreamWriter(buf), EncodeCaller("caller", CallerFullPath))
+	logger.Info("msg")
+	if s := buf.String(); !strings.HasPrefix(s, "caller=/") ||
+		!strings.HasSuffix(s, "/caller_test.go:49 msg=msg\n") {
+		t.Errorf("unexpected the full path: %s", s)
+	}
+
+	var pc uintptr
+	hooks := NewHooks()
+	hooks.Add("pc", func(r *Record) bool { pc = r.PC; return false })
+	logger.WithHooks(hooks).Info("msg")
+	if c := lookupCaller(pc); c == nil || c.path(CallerShortPath) != "caller_test.go:58" {
+		t.Errorf("unexpected the caller pc: %v", c)
+	}
+
+	logger = New("").WithCallerPC(true).WithEncoder(TextEncoder(DiscardWriter(), EncodeCaller("caller")))
+	if n := testing.AllocsPerRun(100, func() { logger.Info("msg") }); n != 0 && !raceEnabled {
+		t.Errorf("expect no allocation, but got %v", n)
+	}
+}
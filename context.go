@@ -0,0 +1,144 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import "context"
+
+type contextKey uint8
+
+const (
+	loggerKey contextKey = iota
+	fieldsKey
+)
+
+// ContextExtractor is used to extract the fields from the context,
+// which should append the extracted fields into fields and return it.
+type ContextExtractor func(ctx context.Context, fields []Field) []Field
+
+// ContextExtractors is the extractors to extract the fields from the context
+// when emitting the log by the methods with the context, such as InfoCtx.
+var ContextExtractors []ContextExtractor
+
+// RegisterContextExtractor adds the extractor into ContextExtractors.
+//
+// Notice: it is not thread-safe and should be called during initializing.
+func RegisterContextExtractor(extractor ContextExtractor) {
+	ContextExtractors = append(ContextExtractors, extractor)
+}
+
+// NewContext returns a new context carrying the logger.
+func NewContext(ctx context.Context, logger *ExtLogger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger carried by the context,
+// or DefalutLogger if no logger.
+func FromContext(ctx context.Context) *ExtLogger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey).(*ExtLogger); ok && logger != nil {
+			return logger
+		}
+	}
+	return DefalutLogger
+}
+
+// ContextWithFields returns a new context carrying the request-scoped fields,
+// which are appended to the fields carried by ctx.
+//
+// They will be added into the log automatically when emitting the log
+// by the methods with the context, such as InfoCtx.
+func ContextWithFields(ctx context.Context, fields ...Field) context.Context {
+	if olds := FieldsFromContext(ctx); len(olds) != 0 {
+		fields = append(olds[:len(olds):len(olds)], fields...)
+	}
+	return context.WithValue(ctx, fieldsKey, fields)
+}
+
+// FieldsFromContext returns the fields carried by the context.
+//
+// Notice: DON'T MODIFY the returned fields.
+func FieldsFromContext(ctx context.Context) []Field {
+	fields, _ := ctx.Value(fieldsKey).([]Field)
+	return fields
+}
+
+// appendContextFields adds the fields carried by the context and extracted
+// by ContextExtractors before fields, and returns the new contexts and fields.
+//
+// The context fields, such as trace_id, are always at the top level, so they
+// are moved before the contexts if the contexts contain the namespace.
+func appendContextFields(ctx context.Context, ctxs, fields []Field) ([]Field, []Field) {
+	ctxFields := FieldsFromContext(ctx)
+	if len(ctxFields) == 0 && len(ContextExtractors) == 0 {
+		return ctxs, fields
+	}
+
+	newFields := make([]Field, 0, len(ctxFields)+len(ctxs)+len(fields)+4)
+	newFields = append(newFields, ctxFields...)
+	for _, extract := range ContextExtractors {
+		newFields = extract(ctx, newFields)
+	}
+	if len(newFields) == 0 {
+		return ctxs, fields
+	}
+
+	if hasNamespace(ctxs) {
+		newFields = append(newFields, ctxs...)
+		ctxs = nil
+	}
+	return ctxs, append(newFields, fields...)
+}
+
+// TraceCtx is equal to l.Trace(msg, fields...), but also adds the fields
+// from the context.
+func (l *ExtLogger) TraceCtx(ctx context.Context, msg string, fields ...Field) {
+	l.LogCtx(ctx, LvlTrace, 1, msg, nil, fields)
+}
+
+// DebugCtx is equal to l.Debug(msg, fields...), but also adds the fields
+// from the context.
+func (l *ExtLogger) DebugCtx(ctx context.Context, msg string, fields ...Field) {
+	l.LogCtx(ctx, LvlDebug, 1, msg, nil, fields)
+}
+
+// InfoCtx is equal to l.Info(msg, fields...), but also adds the fields
+// from the context.
+func (l *ExtLogger) InfoCtx(ctx context.Context, msg string, fields ...Field) {
+	l.LogCtx(ctx, LvlInfo, 1, msg, nil, fields)
+}
+
+// WarnCtx is equal to l.Warn(msg, fields...), but also adds the fields
+// from the context.
+func (l *ExtLogger) WarnCtx(ctx context.Context, msg string, fields ...Field) {
+	l.LogCtx(ctx, LvlWarn, 1, msg, nil, fields)
+}
+
+// ErrorCtx is equal to l.Error(msg, fields...), but also adds the fields
+// from the context.
+func (l *ExtLogger) ErrorCtx(ctx context.Context, msg string, fields ...Field) {
+	l.LogCtx(ctx, LvlError, 1, msg, nil, fields)
+}
+
+// PanicCtx is equal to l.Panic(msg, fields...), but also adds the fields
+// from the context.
+func (l *ExtLogger) PanicCtx(ctx context.Context, msg string, fields ...Field) {
+	l.LogCtx(ctx, LvlPanic, 1, msg, nil, fields)
+}
+
+// FatalCtx is equal to l.Fatal(msg, fields...), but also adds the fields
+// from the context.
+func (l *ExtLogger) FatalCtx(ctx context.Context, msg string, fields ...Field) {
+	l.LogCtx(ctx, LvlFatal, 1, msg, nil, fields)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"context"
+	"testing"
+)
+
+type tenantKey struct{}
+
+func TestContext(t *testing.T) {
+	if FromContext(context.Background()) != DefalutLogger {
+		t.Error("expect DefalutLogger")
+	}
+
+	buf := NewBuilder(128)
+	logger := New("").WithCtx(Caller("caller"))
+	logger.Encoder = TextEncoder(StreamWriter(buf))
+
+	ctx := NewContext(context.Background(), logger)
+	if FromContext(ctx) != logger {
+		t.Error("expect the logger in the context")
+	}
+
+	defer func(extractors []ContextExtractor) { ContextExtractors = extractors }(ContextExtractors)
+	RegisterContextExtractor(func(ctx context.Context, fields []Field) []Field {
+		if tenant, ok := ctx.Value(tenantKey{}).(string); ok {
+			fields = append(fields, F("tenant", tenant))
+		}
+		return fields
+	})
+
+	ctx = ContextWithFields(ctx, F("reqid", 123))
+	ctx = ContextWithFields(ctx, F("user", "xgfone"))
+	ctx = context.WithValue(ctx, tenantKey{}, "abc")
+	FromContext(ctx).InfoCtx(ctx, "msg", F("key", "value"))
+
+	expect := "caller=context_test.go:49 reqid=123 user=xgfone tenant=abc key=value msg=msg\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Info("msg", F("key", "value"))
+	if expect = "caller=context_test.go:57 key=value msg=msg\n"; buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
@@ -15,6 +15,7 @@
 package klog
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -26,11 +27,16 @@ type Record struct {
 	Name  string    // The logger name, which may be empty
 	Time  time.Time // The start time when to emit the log
 	Depth int       // The stack depth of the caller
+	PC    uintptr   // The program counter of the caller, which may be 0
 
 	Lvl    Level   // The log level
 	Msg    string  // The log message
 	Ctxs   []Field // The SHARED key-value contexts. DON'T MODIFY IT!
 	Fields []Field // The key-value pairs
+
+	Ctx context.Context // The context passed by LogCtx, which may be nil
+
+	ctxsCache *ctxsCache // The cache of the pre-encoded Ctxs, which may be nil
 }
 
 // Encoder is used to encode the log record and to write it into the writer.
@@ -107,16 +113,30 @@ func (ef *encoderFunc) Encode(r Record) {
 type EncoderOption interface{}
 
 type option struct {
-	Quote   bool
-	Newline bool
+	Quote      bool
+	Newline    bool
+	EscapeHTML bool
 
 	TimeKey string
 	TimeFmt string
+	TimeLoc *time.Location
 
 	LevelKey  string
 	LoggerKey string
+
+	CallerKey  string
+	CallerMode CallerPathMode
+	FuncKey    string
+
+	Color int8
 }
 
+const (
+	colorAuto int8 = iota
+	colorAlways
+	colorNever
+)
+
 func getOption(options ...EncoderOption) (o option) {
 	o.Newline = true
 	for _, opt := range options {
@@ -131,8 +151,36 @@ func getOption(options ...EncoderOption) (o option) {
 // to surround the string value if it contains the space.
 func Quote() EncoderOption { return func(o *option) { o.Quote = true } }
 
+// EscapeHTML is used by JSONEncoder, which will escape the HTML characters
+// '<', '>' and '&' in the JSON strings, like encoding/json.
+func EscapeHTML() EncoderOption { return func(o *option) { o.EscapeHTML = true } }
+
+// Colorize is used by ConsoleEncoder to enable or disable the colors forcibly,
+// which are enabled automatically only if the writer is the terminal
+// and the environment variable NO_COLOR is not set by default.
+func Colorize(on bool) EncoderOption {
+	return func(o *option) {
+		if on {
+			o.Color = colorAlways
+		} else {
+			o.Color = colorNever
+		}
+	}
+}
+
+// Predefine some special time formats for EncodeTime, which encode the time
+// as the unix integer timestamp.
+const (
+	TimeFmtUnix      = "unix"      // The unix seconds
+	TimeFmtUnixMilli = "unixmilli" // The unix milliseconds
+	TimeFmtUnixNano  = "unixnano"  // The unix nanoseconds
+)
+
 // EncodeTime enables the encoder to encode the time as the format with the key,
 // which will encode the time as the integer second if format is missing.
+//
+// format may be the layout supported by time.Time.Format, or one of
+// TimeFmtUnix, TimeFmtUnixMilli and TimeFmtUnixNano.
 func EncodeTime(key string, format ...string) EncoderOption {
 	return func(o *option) {
 		o.TimeKey = key
@@ -142,6 +190,36 @@ func EncodeTime(key string, format ...string) EncoderOption {
 	}
 }
 
+// TimeLocation converts the time of the record to the location before
+// encoding it, such as time.UTC or time.Local.
+//
+// It does not affect the time fields.
+func TimeLocation(loc *time.Location) EncoderOption {
+	return func(o *option) { o.TimeLoc = loc }
+}
+
+// EncodeCaller enables the encoder to encode the caller "file:line" with the key,
+// the file path of which is trimmed by mode, CallerShortPath by default.
+//
+// The caller is resolved from Record.PC, or from Record.Depth if it is 0.
+// So it is recommended to enable ExtLogger.CallerPC.
+func EncodeCaller(key string, mode ...CallerPathMode) EncoderOption {
+	return func(o *option) {
+		o.CallerKey = key
+		if len(mode) > 0 {
+			o.CallerMode = mode[0]
+		}
+	}
+}
+
+// EncodeCallerFunc enables the encoder to encode the full function name
+// of the caller with the key, such as "github.com/xgfone/klog/v4.TestFunc".
+//
+// The caller is resolved like EncodeCaller.
+func EncodeCallerFunc(key string) EncoderOption {
+	return func(o *option) { o.FuncKey = key }
+}
+
 // EncodeLevel enables the encoder to encode the level with the key.
 func EncodeLevel(key string) EncoderOption {
 	return func(o *option) { o.LevelKey = key }
@@ -183,18 +261,66 @@ func encodeTime(buf *Builder, t time.Time, format string) {
 		t = time.Now()
 	}
 
-	if format == "" {
+	switch format {
+	case "", TimeFmtUnix:
 		buf.AppendInt(t.Unix())
-	} else {
+	case TimeFmtUnixMilli:
+		buf.AppendInt(t.Unix()*1e3 + int64(t.Nanosecond())/1e6)
+	case TimeFmtUnixNano:
+		buf.AppendInt(t.UnixNano())
+	default:
 		buf.AppendTime(t, format)
 	}
 }
 
-func textEncodeFields(buf *Builder, fields []Field, depth int, quote bool, timeFmt string) {
+// getCaller returns the caller of the record if it is enabled to encode
+// the caller or the function, or nil.
+//
+// depth is the stack depth of the caller of the record, and 0 identifies
+// the caller of getCaller.
+func (o *option) getCaller(r Record, depth int) *callerInfo {
+	if o.CallerKey == "" && o.FuncKey == "" {
+		return nil
+	} else if r.PC != 0 {
+		return lookupCaller(r.PC)
+	}
+	return lookupCaller(callerPC(depth + 1))
+}
+
+// encodeRecordTime encodes the time of the record by the time options.
+func encodeRecordTime(buf *Builder, t time.Time, opt *option) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if opt.TimeLoc != nil {
+		t = t.In(opt.TimeLoc)
+	}
+	encodeTime(buf, t, opt.TimeFmt)
+}
+
+// textEncodeFields encodes the fields, the keys of which are prefixed
+// by prefix, and returns the new prefix changed by the namespace fields.
+func textEncodeFields(buf *Builder, fields []Field, depth int, quote bool,
+	timeFmt, prefix string) string {
 	depth++
 	for _, field := range fields {
-		buf.WriteString(field.Key())
-		buf.WriteByte('=')
+		if f, ok := asTypedField(field); ok {
+			switch f.typ {
+			case namespaceType:
+				prefix = prefix + f.key + "."
+				continue
+			case objectType:
+				obj, _ := f.any.(ObjectMarshaler)
+				textEncodeObject(buf, prefix+f.key, obj, quote, timeFmt)
+			default:
+				buf.WriteString(prefix)
+				buf.WriteString(f.key)
+				buf.WriteByte('=')
+				textEncodeTypedField(buf, f, quote, timeFmt)
+			}
+			buf.WriteByte(' ')
+			continue
+		}
 
 		var value interface{}
 		if s, ok := field.(StackField); ok {
@@ -203,6 +329,17 @@ func textEncodeFields(buf *Builder, fields []Field, depth int, quote bool, timeF
 			value = field.Value()
 		}
 
+		// The keys of the object are encoded as the dotted keys.
+		if obj, ok := value.(ObjectMarshaler); ok {
+			textEncodeObject(buf, prefix+field.Key(), obj, quote, timeFmt)
+			buf.WriteByte(' ')
+			continue
+		}
+
+		buf.WriteString(prefix)
+		buf.WriteString(field.Key())
+		buf.WriteByte('=')
+
 		appendSpace := true
 		switch v := value.(type) {
 		case FieldError:
@@ -210,16 +347,18 @@ func textEncodeFields(buf *Builder, fields []Field, depth int, quote bool, timeF
 			if fields := v.Fields(); len(fields) != 0 {
 				appendSpace = false
 				buf.WriteByte(' ')
-				textEncodeFields(buf, fields, depth-1, quote, timeFmt)
+				prefix = textEncodeFields(buf, fields, depth-1, quote, timeFmt, prefix)
 			}
 			v.Release()
 		case FieldReleaser:
 			if fields := v.Fields(); len(fields) != 0 {
 				appendSpace = false
 				buf.WriteByte(' ')
-				textEncodeFields(buf, fields, depth-1, quote, timeFmt)
+				prefix = textEncodeFields(buf, fields, depth-1, quote, timeFmt, prefix)
 			}
 			v.Release()
+		case ArrayMarshaler:
+			textEncodeValue(buf, field.Key(), v, quote, timeFmt)
 		case string:
 			appendString(buf, v, quote)
 		case error:
@@ -244,6 +383,7 @@ func textEncodeFields(buf *Builder, fields []Field, depth int, quote bool, timeF
 			buf.WriteByte(' ')
 		}
 	}
+	return prefix
 }
 
 // TextEncoder encodes the key-values log as the text.
@@ -251,6 +391,10 @@ func textEncodeFields(buf *Builder, fields []Field, depth int, quote bool, timeF
 // Notice: The message will use "msg" as the key.
 func TextEncoder(w Writer, options ...EncoderOption) Encoder {
 	opt := getOption(options...)
+	encodeFields := func(buf *Builder, fs []Field, depth int, prefix string) (string, int) {
+		return textEncodeFields(buf, fs, depth+1, opt.Quote, opt.TimeFmt, prefix), 0
+	}
+
 	return EncoderFunc(w, func(buf *Builder, r Record) {
 		r.Depth++
 
@@ -258,7 +402,7 @@ func TextEncoder(w Writer, options ...EncoderOption) Encoder {
 		if opt.TimeKey != "" {
 			buf.WriteString(opt.TimeKey)
 			buf.WriteByte('=')
-			encodeTime(buf, r.Time, opt.TimeFmt)
+			encodeRecordTime(buf, r.Time, &opt)
 			buf.WriteByte(' ')
 		}
 
@@ -278,9 +422,25 @@ func TextEncoder(w Writer, options ...EncoderOption) Encoder {
 			buf.WriteByte(' ')
 		}
 
+		// Caller
+		if c := opt.getCaller(r, r.Depth); c != nil {
+			if opt.CallerKey != "" {
+				buf.WriteString(opt.CallerKey)
+				buf.WriteByte('=')
+				appendString(buf, c.path(opt.CallerMode), opt.Quote)
+				buf.WriteByte(' ')
+			}
+			if opt.FuncKey != "" {
+				buf.WriteString(opt.FuncKey)
+				buf.WriteByte('=')
+				appendString(buf, c.function, opt.Quote)
+				buf.WriteByte(' ')
+			}
+		}
+
 		// Ctxs and Fields
-		textEncodeFields(buf, r.Ctxs, r.Depth, opt.Quote, opt.TimeFmt)
-		textEncodeFields(buf, r.Fields, r.Depth, opt.Quote, opt.TimeFmt)
+		prefix, _ := encodeCtxs(buf, r, r.Depth, &opt, encodeFields)
+		textEncodeFields(buf, r.Fields, r.Depth, opt.Quote, opt.TimeFmt, prefix)
 
 		// Message
 		buf.WriteString("msg=")
@@ -292,13 +452,27 @@ func TextEncoder(w Writer, options ...EncoderOption) Encoder {
 	})
 }
 
-func jsonEncodeFields(buf *Builder, fields []Field, depth int, timeFmt string) {
+// jsonEncodeFields encodes the fields and returns the number of the objects
+// opened by the namespace fields, which should be closed by jsonCloseObjects.
+func jsonEncodeFields(buf *Builder, fields []Field, depth int, timeFmt string) (opened int) {
 	depth++
 	for _, field := range fields {
 		// Key
 		buf.AppendJSONString(field.Key())
 		buf.WriteString(`:`)
 
+		if f, ok := asTypedField(field); ok {
+			if f.typ == namespaceType {
+				buf.WriteByte('{')
+				opened++
+				continue
+			}
+
+			jsonEncodeTypedField(buf, f, timeFmt)
+			buf.WriteByte(',')
+			continue
+		}
+
 		var value interface{}
 		if s, ok := field.(StackField); ok {
 			value = s.Stack(depth)
@@ -318,16 +492,18 @@ func jsonEncodeFields(buf *Builder, fields []Field, depth int, timeFmt string) {
 			if fields := v.Fields(); len(fields) != 0 {
 				appendComma = false
 				buf.WriteByte(',')
-				jsonEncodeFields(buf, fields, depth-1, timeFmt)
+				opened += jsonEncodeFields(buf, fields, depth-1, timeFmt)
 			}
 			v.Release()
 		case FieldReleaser:
 			if fields := v.Fields(); len(fields) != 0 {
 				appendComma = false
 				buf.WriteByte(',')
-				jsonEncodeFields(buf, fields, depth-1, timeFmt)
+				opened += jsonEncodeFields(buf, fields, depth-1, timeFmt)
 			}
 			v.Release()
+		case ObjectMarshaler, ArrayMarshaler:
+			jsonEncodeValue(buf, field.Key(), v, timeFmt)
 		default:
 			if err := buf.AppendJSON(v); err != nil {
 				buf.AppendJSONString(fmt.Sprintf(`<klog.TextEncoder:Error:%s>`, err.Error()))
@@ -338,6 +514,17 @@ func jsonEncodeFields(buf *Builder, fields []Field, depth int, timeFmt string) {
 			buf.WriteByte(',')
 		}
 	}
+	return
+}
+
+// jsonCloseObjects closes the n objects opened by the namespace fields.
+func jsonCloseObjects(buf *Builder, n int) {
+	for ; n > 0; n-- {
+		if bs := buf.Bytes(); bs[len(bs)-1] == ',' {
+			buf.TruncateAfter(1)
+		}
+		buf.WriteString("},")
+	}
 }
 
 // JSONEncoder encodes the key-values log as json.
@@ -349,8 +536,14 @@ func jsonEncodeFields(buf *Builder, fields []Field, depth int, timeFmt string) {
 // Notice: it will ignore the empty msg.
 func JSONEncoder(w Writer, options ...EncoderOption) Encoder {
 	opt := getOption(options...)
+	encodeFields := func(buf *Builder, fs []Field, depth int, _ string) (string, int) {
+		buf.SetEscapeHTML(opt.EscapeHTML)
+		return "", jsonEncodeFields(buf, fs, depth+1, opt.TimeFmt)
+	}
+
 	return EncoderFunc(w, func(buf *Builder, r Record) {
 		r.Depth++
+		buf.SetEscapeHTML(opt.EscapeHTML)
 		buf.WriteByte('{')
 
 		// Time
@@ -358,7 +551,7 @@ func JSONEncoder(w Writer, options ...EncoderOption) Encoder {
 			buf.WriteByte('"')
 			buf.WriteString(opt.TimeKey)
 			buf.WriteString(`":"`)
-			encodeTime(buf, r.Time, opt.TimeFmt)
+			encodeRecordTime(buf, r.Time, &opt)
 			buf.WriteString(`",`)
 		}
 
@@ -380,9 +573,26 @@ func JSONEncoder(w Writer, options ...EncoderOption) Encoder {
 			buf.WriteString(`",`)
 		}
 
+		// Caller
+		if c := opt.getCaller(r, r.Depth); c != nil {
+			if opt.CallerKey != "" {
+				buf.AppendJSONString(opt.CallerKey)
+				buf.WriteByte(':')
+				buf.AppendJSONString(c.path(opt.CallerMode))
+				buf.WriteByte(',')
+			}
+			if opt.FuncKey != "" {
+				buf.AppendJSONString(opt.FuncKey)
+				buf.WriteByte(':')
+				buf.AppendJSONString(c.function)
+				buf.WriteByte(',')
+			}
+		}
+
 		// Ctxs and Fields
-		jsonEncodeFields(buf, r.Ctxs, r.Depth, opt.TimeFmt)
-		jsonEncodeFields(buf, r.Fields, r.Depth, opt.TimeFmt)
+		_, opened := encodeCtxs(buf, r, r.Depth, &opt, encodeFields)
+		opened += jsonEncodeFields(buf, r.Fields, r.Depth, opt.TimeFmt)
+		jsonCloseObjects(buf, opened)
 
 		// Message
 		buf.WriteString(`"msg":`)
@@ -0,0 +1,324 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const consoleTimeFmt = "2006-01-02 15:04:05.000"
+
+const (
+	colorReset = "\x1b[0m"
+	colorFaint = "\x1b[2m"
+)
+
+// consoleLevelColor returns the ANSI color of the level.
+func consoleLevelColor(lvl Level) string {
+	switch lvl {
+	case LvlTrace:
+		return "\x1b[90m" // Gray
+	case LvlDebug:
+		return "\x1b[36m" // Cyan
+	case LvlInfo:
+		return "\x1b[32m" // Green
+	case LvlWarn:
+		return "\x1b[33m" // Yellow
+	case LvlError:
+		return "\x1b[31m" // Red
+	case LvlPanic:
+		return "\x1b[1;35m" // Bold Magenta
+	case LvlFatal:
+		return "\x1b[1;31m" // Bold Red
+	default:
+		return ""
+	}
+}
+
+// isTerminal reports whether the writer is the terminal.
+func isTerminal(w Writer) bool {
+	if f := writerFile(w); f != nil {
+		fi, err := f.Stat()
+		return err == nil && fi.Mode()&os.ModeCharDevice != 0
+	}
+	return false
+}
+
+// writerFile returns the *os.File wrapped by StreamWriter, which may be
+// wrapped by SafeWriter or LevelWriter again, or nil.
+func writerFile(w Writer) *os.File {
+	for {
+		switch v := w.(type) {
+		case streamWriter:
+			f, _ := v.Writer.(*os.File)
+			return f
+		case writerFunc:
+			if v.inner == nil {
+				return nil
+			}
+			w = v.inner
+		default:
+			return nil
+		}
+	}
+}
+
+// ConsoleEncoder encodes the log as the human-friendly line for the terminal
+// in the development, the format of which is
+//
+//	TIME LEVEL [LOGGER] [CALLER] [FUNCTION] MESSAGE  KEY1=VALUE1 KEY2=VALUE2 ...
+//	    ERROR_KEY: ERROR
+//	    STACK_KEY:
+//	        FRAME1
+//	        FRAME2
+//
+// The time, level and logger are aligned as the columns. The error fields
+// and the stack fields with more than one frame, such as CallerStack,
+// are rendered on their own lines below the log line.
+//
+// The level is colored by the ANSI colors only if the writer is the terminal
+// and the environment variable NO_COLOR is not set, which may be overridden
+// by the option Colorize. The terminal is detected from the *os.File
+// wrapped by StreamWriter, which may be wrapped by SafeWriter or LevelWriter.
+//
+// The time is encoded by the format of the option EncodeTime,
+// or "2006-01-02 15:04:05.000" by default. And the other options
+// except TimeLocation, EncodeCaller, EncodeCallerFunc and Newline are ignored,
+// and the keys of the caller and the function are not output.
+func ConsoleEncoder(w Writer, options ...EncoderOption) Encoder {
+	e := &consoleEncoder{opt: getOption(options...)}
+	if e.opt.TimeFmt == "" {
+		e.opt.TimeFmt = consoleTimeFmt
+	}
+	e.SetWriter(w)
+	return e
+}
+
+type consoleEncoder struct {
+	writer Writer
+	color  bool
+	opt    option
+	width  int32 // The maximum width of the logger names
+}
+
+func (e *consoleEncoder) Writer() Writer { return e.writer }
+func (e *consoleEncoder) SetWriter(w Writer) {
+	if w == nil {
+		panic("ConsoleEncoder: the writer must not be nil")
+	}
+
+	e.writer = w
+	switch e.opt.Color {
+	case colorAuto:
+		e.color = os.Getenv("NO_COLOR") == "" && isTerminal(w)
+	default:
+		e.color = e.opt.Color == colorAlways
+	}
+}
+
+func (e *consoleEncoder) Encode(r Record) {
+	r.Depth++
+	buf, extra := getBuilder(), getBuilder()
+	e.encode(buf, extra, r)
+	e.writer.WriteLevel(r.Lvl, buf.Bytes())
+	putBuilder(extra)
+	putBuilder(buf)
+}
+
+func (e *consoleEncoder) encode(buf, extra *Builder, r Record) {
+	r.Depth++
+
+	// Time
+	if e.color {
+		buf.WriteString(colorFaint)
+	}
+	encodeRecordTime(buf, r.Time, &e.opt)
+	if e.color {
+		buf.WriteString(colorReset)
+	}
+	buf.WriteByte(' ')
+
+	// Level
+	color := ""
+	if e.color {
+		color = consoleLevelColor(r.Lvl)
+		buf.WriteString(color)
+	}
+	level := r.Lvl.String()
+	buf.WriteString(level)
+	if color != "" {
+		buf.WriteString(colorReset)
+	}
+	consolePad(buf, 5-len(level)+1)
+
+	// Logger Name
+	width := int(atomic.LoadInt32(&e.width))
+	for width < len(r.Name) {
+		if atomic.CompareAndSwapInt32(&e.width, int32(width), int32(len(r.Name))) {
+			width = len(r.Name)
+		} else {
+			width = int(atomic.LoadInt32(&e.width))
+		}
+	}
+	if width > 0 {
+		buf.WriteString(r.Name)
+		consolePad(buf, width-len(r.Name)+1)
+	}
+
+	// Caller
+	if c := e.opt.getCaller(r, r.Depth); c != nil {
+		if e.opt.CallerKey != "" {
+			buf.WriteString(c.path(e.opt.CallerMode))
+			buf.WriteByte(' ')
+		}
+		if e.opt.FuncKey != "" {
+			buf.WriteString(c.function)
+			buf.WriteByte(' ')
+		}
+	}
+
+	// Message
+	buf.WriteString(r.Msg)
+
+	// Ctxs and Fields
+	start := buf.Len()
+	buf.WriteString("  ")
+	prefix := consoleEncodeFields(buf, extra, r.Ctxs, r.Depth, e.opt.TimeFmt, color, "")
+	consoleEncodeFields(buf, extra, r.Fields, r.Depth, e.opt.TimeFmt, color, prefix)
+	if buf.Len() == start+2 {
+		buf.TruncateAfter(2)
+	} else {
+		buf.TruncateAfter(1) // Remove the trailing space.
+	}
+
+	buf.Write(extra.Bytes())
+	if e.opt.Newline {
+		buf.WriteByte('\n')
+	}
+}
+
+func consolePad(buf *Builder, n int) {
+	for ; n > 0; n-- {
+		buf.WriteByte(' ')
+	}
+}
+
+// consoleEncodeFields is the same as textEncodeFields, but writes the errors
+// and the stacks into extra as the multiple lines.
+func consoleEncodeFields(buf, extra *Builder, fields []Field, depth int,
+	timeFmt, color, prefix string) string {
+	depth++
+	for i, field := range fields {
+		if f, ok := asTypedField(field); ok {
+			if f.typ != errorType || f.any == nil {
+				prefix = textEncodeFields(buf, fields[i:i+1], depth, true, timeFmt, prefix)
+				continue
+			}
+		}
+
+		var value interface{}
+		switch f := field.(type) {
+		case StackField:
+			value = f.Stack(depth)
+			if s, ok := value.(string); ok && isConsoleStack(s) {
+				consoleBlockKey(extra, prefix, f.Key(), color)
+				for _, frame := range strings.Fields(s[1 : len(s)-1]) {
+					extra.WriteString("\n        ")
+					extra.WriteString(frame)
+				}
+				continue
+			}
+
+			buf.WriteString(prefix)
+			buf.WriteString(f.Key())
+			buf.WriteByte('=')
+			textEncodeValue(buf, f.Key(), value, true, timeFmt)
+			buf.WriteByte(' ')
+			continue
+
+		default:
+			value = field.Value()
+		}
+
+		err, ok := value.(error)
+		if !ok || err == nil {
+			prefix = textEncodeFields(buf, fields[i:i+1], depth, true, timeFmt, prefix)
+			continue
+		}
+
+		consoleBlockKey(extra, prefix, field.Key(), color)
+		extra.WriteByte(' ')
+		consoleWriteLines(extra, consoleErrorString(err))
+
+		if fe, ok := err.(FieldError); ok {
+			if fields := fe.Fields(); len(fields) != 0 {
+				prefix = consoleEncodeFields(buf, extra, fields, depth-1, timeFmt, color, prefix)
+			}
+			fe.Release()
+		}
+	}
+	return prefix
+}
+
+// isConsoleStack reports whether s is the stack with more than one frame,
+// such as "[file1.go:1 file2.go:2]".
+func isConsoleStack(s string) bool {
+	return len(s) > 2 && s[0] == '[' && s[len(s)-1] == ']' &&
+		strings.IndexByte(s, ' ') > 0
+}
+
+func consoleBlockKey(extra *Builder, prefix, key, color string) {
+	extra.WriteString("\n    ")
+	if color != "" {
+		extra.WriteString(color)
+	}
+	extra.WriteString(prefix)
+	extra.WriteString(key)
+	extra.WriteByte(':')
+	if color != "" {
+		extra.WriteString(colorReset)
+	}
+}
+
+// consoleErrorString returns the detail of the error, such as the stack
+// carried by the error implementing fmt.Formatter like github.com/pkg/errors.
+func consoleErrorString(err error) string {
+	if fe, ok := err.(fieldError); ok {
+		err = fe.error
+	}
+	if _, ok := err.(fmt.Formatter); ok {
+		return fmt.Sprintf("%+v", err)
+	}
+	return err.Error()
+}
+
+// consoleWriteLines writes the multi-line string with the indent.
+func consoleWriteLines(extra *Builder, s string) {
+	s = strings.TrimRight(s, "\n")
+	for {
+		index := strings.IndexByte(s, '\n')
+		if index < 0 {
+			extra.WriteString(s)
+			return
+		}
+
+		extra.WriteString(strings.TrimRight(s[:index], "\r"))
+		extra.WriteString("\n      ")
+		s = s[index+1:]
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestConsoleEncoder(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	stack := StackFieldFunc("stack", func(int) interface{} { return "[a.go:1 b.go:2]" })
+	caller := StackFieldFunc("caller", func(int) interface{} { return "a.go:1" })
+
+	buf := NewBuilder(512)
+	enc := ConsoleEncoder(StreamWriter(buf))
+	enc.Encode(Record{Name: "db", Time: now, Lvl: LvlInfo, Msg: "start", Ctxs: []Field{F("k", "a b")}})
+	enc.Encode(Record{Name: "http", Time: now, Lvl: LvlError, Msg: "request failed",
+		Fields: []Field{Int("code", 500), Err("err", errors.New("line1\nline2")),
+			caller, stack, E(FE(errors.New("timeout"), FB(1).F("retry", 3)))}})
+	enc.Encode(Record{Time: now, Lvl: LvlWarn, Msg: "no fields"})
+
+	expect := "2020-01-02 03:04:05.006 INFO  db start  k=\"a b\"\n" +
+		"2020-01-02 03:04:05.006 ERROR http request failed  code=500 caller=a.go:1 retry=3\n" +
+		"    err: line1\n      line2\n" +
+		"    stack:\n        a.go:1\n        b.go:2\n" +
+		"    err: timeout\n" +
+		"2020-01-02 03:04:05.006 WARN       no fields\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	// Colors
+	buf.Reset()
+	enc = ConsoleEncoder(StreamWriter(buf), Colorize(true), EncodeTime("", time.RFC3339))
+	enc.Encode(Record{Time: now, Lvl: LvlError, Msg: "msg", Fields: []Field{E(errors.New("error"))}})
+	expect = "\x1b[2m2020-01-02T03:04:05Z\x1b[0m \x1b[31mERROR\x1b[0m msg\n" +
+		"    \x1b[31merr:\x1b[0m error\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%q', but got '%q'", expect, buf.String())
+	}
+
+	if isTerminal(StreamWriter(buf)) {
+		t.Errorf("unexpected the terminal")
+	}
+	if f := writerFile(SafeWriter(LevelWriter(LvlInfo, StreamWriter(os.Stdout)))); f != os.Stdout {
+		t.Errorf("expect the file os.Stdout, but got %v", f)
+	}
+	if f := writerFile(SafeWriter(DiscardWriter())); f != nil {
+		t.Errorf("expect no file, but got %v", f)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxCtxsCacheEntries is the maximum number of the encoders whose encoded
+// contexts are cached by a logger, which is enough for the most cases.
+// If the cache is full, the oldest entry will be replaced.
+const maxCtxsCacheEntries = 4
+
+// ctxsEncodeFunc encodes the fields with the namespace prefix, and returns
+// the new prefix and the number of the objects opened by the namespaces.
+type ctxsEncodeFunc func(buf *Builder, fields []Field, depth int,
+	prefix string) (newPrefix string, opened int)
+
+// ctxsCache caches the contexts of a logger pre-encoded by the encoders,
+// which is created for each logger derived by WithCtx.
+type ctxsCache struct {
+	lock    sync.Mutex
+	entries atomic.Value // []*encodedCtxs
+}
+
+// encodedCtxs is the contexts pre-encoded by an encoder, which consists of
+// the segments of the static contexts encoded in advance and the dynamic
+// contexts encoded per record.
+type encodedCtxs struct {
+	owner    interface{}
+	ctxs     []Field
+	segments []ctxsSegment // nil if there is no static context
+	prefix   string
+	opened   int
+}
+
+type ctxsSegment struct {
+	data   []byte // The encoded static contexts
+	index  int    // The index of the dynamic context, or -1
+	prefix string // The namespace prefix of the dynamic context
+}
+
+func (e *encodedCtxs) match(owner interface{}, ctxs []Field) bool {
+	return e.owner == owner && len(e.ctxs) == len(ctxs) && &e.ctxs[0] == &ctxs[0]
+}
+
+// load returns the contexts pre-encoded by the encoder identified by owner,
+// or encodes and caches them if missing, which replaces the oldest entry
+// if the cache is full.
+//
+// It returns nil if c is nil.
+func (c *ctxsCache) load(owner interface{}, ctxs []Field, encode ctxsEncodeFunc) *encodedCtxs {
+	if c == nil || len(ctxs) == 0 {
+		return nil
+	}
+
+	entries, _ := c.entries.Load().([]*encodedCtxs)
+	for _, e := range entries {
+		if e.match(owner, ctxs) {
+			return e
+		}
+	}
+
+	e := newEncodedCtxs(owner, ctxs, encode)
+	c.lock.Lock()
+	entries, _ = c.entries.Load().([]*encodedCtxs)
+	if len(entries) >= maxCtxsCacheEntries {
+		entries = entries[len(entries)-maxCtxsCacheEntries+1:]
+	}
+	newEntries := make([]*encodedCtxs, 0, len(entries)+1)
+	newEntries = append(newEntries, entries...)
+	c.entries.Store(append(newEntries, e))
+	c.lock.Unlock()
+
+	return e
+}
+
+func newEncodedCtxs(owner interface{}, ctxs []Field, encode ctxsEncodeFunc) *encodedCtxs {
+	e := &encodedCtxs{owner: owner, ctxs: ctxs}
+	buf := getBuilder()
+	defer putBuilder(buf)
+
+	var static bool
+	var start int
+	for i, field := range ctxs {
+		if isStaticField(field) {
+			static = true
+			continue
+		}
+
+		if start < i {
+			e.encodeStatic(buf, ctxs[start:i], encode)
+		}
+		e.segments = append(e.segments, ctxsSegment{index: i, prefix: e.prefix})
+		start = i + 1
+	}
+
+	if !static {
+		e.segments = nil
+	} else if start < len(ctxs) {
+		e.encodeStatic(buf, ctxs[start:], encode)
+	}
+	return e
+}
+
+func (e *encodedCtxs) encodeStatic(buf *Builder, fields []Field, encode ctxsEncodeFunc) {
+	buf.Reset()
+	prefix, opened := encode(buf, fields, 0, e.prefix)
+	data := append([]byte(nil), buf.Bytes()...)
+	e.segments = append(e.segments, ctxsSegment{data: data, index: -1})
+	e.prefix = prefix
+	e.opened += opened
+}
+
+// isStaticField reports whether the value of the field is immutable
+// so that the field can be encoded in advance.
+//
+// The lazy fields, StackFields and the values of the other types,
+// such as fmt.Stringer, error and ObjectMarshaler, are not static,
+// because the encoded results may be changed. So is the zero time.Time,
+// which is encoded as the current time.
+func isStaticField(f Field) bool {
+	switch v := f.(type) {
+	case typedField: // *typedField may be released and modified.
+		switch v.typ {
+		case intType, uintType, floatType, boolType, stringType, durationType,
+			timeType, namespaceType:
+			return true
+		case timeFullType:
+			return !v.any.(time.Time).IsZero()
+		}
+	case field:
+		switch value := v.value.(type) {
+		case nil, bool, string, time.Duration, float32, float64,
+			int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			return true
+		case time.Time:
+			return !value.IsZero()
+		}
+	}
+	return false
+}
+
+// encodeCtxs encodes the contexts of the record by encode, which reuses
+// the static contexts pre-encoded by the encoder identified by owner
+// and only encodes the dynamic contexts per record.
+//
+// It returns the namespace prefix and the number of the opened objects
+// like ctxsEncodeFunc.
+func encodeCtxs(buf *Builder, r Record, depth int, owner interface{},
+	encode ctxsEncodeFunc) (prefix string, opened int) {
+	depth++
+	e := r.ctxsCache.load(owner, r.Ctxs, encode)
+	if e == nil || e.segments == nil {
+		return encode(buf, r.Ctxs, depth, "")
+	}
+
+	var n int
+	for _, s := range e.segments {
+		if s.index < 0 {
+			buf.Write(s.data)
+		} else {
+			_, n = encode(buf, r.Ctxs[s.index:s.index+1], depth, s.prefix)
+			opened += n
+		}
+	}
+	return e.prefix, e.opened + opened
+}
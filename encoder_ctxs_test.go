@@ -0,0 +1,124 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEncodeCtxs(t *testing.T) {
+	var count int
+	lazy := F("count", func() interface{} { count++; return count })
+
+	buf := NewBuilder(512)
+	logger := New("").WithEncoder(TextEncoder(StreamWriter(buf)))
+	logger = logger.WithCtx(F("k1", "v1"), lazy, Caller("caller"), Int("k2", 2)).
+		WithGroup("ns").WithCtx(Str("k3", "v3"), lazy)
+
+	logger.Info("msg", F("k4", 4))
+	logger.Info("msg", F("k4", 4))
+	expect := "k1=v1 count=1 caller=encoder_ctxs_test.go:32 k2=2 ns.k3=v3 ns.count=2 ns.k4=4 msg=msg\n" +
+		"k1=v1 count=3 caller=encoder_ctxs_test.go:33 k2=2 ns.k3=v3 ns.count=4 ns.k4=4 msg=msg\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Encoder = JSONEncoder(StreamWriter(buf))
+	logger.Info("msg", F("k4", 4))
+	logger.Info("msg", F("k4", 4))
+	expect = `{"k1":"v1","count":5,"caller":"encoder_ctxs_test.go:42","k2":2,"ns":{"k3":"v3","count":6,"k4":4},"msg":"msg"}` + "\n" +
+		`{"k1":"v1","count":7,"caller":"encoder_ctxs_test.go:43","k2":2,"ns":{"k3":"v3","count":8,"k4":4},"msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Encoder = LogfmtEncoder(StreamWriter(buf))
+	logger.WithCtx(F("k 5", "a b")).Info("msg")
+	expect = `k1=v1 count=9 caller=encoder_ctxs_test.go:52 k2=2 ns.k3=v3 ns.count=10 ns.k_5="a b" msg=msg` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
+
+func TestEncodeCtxsAllocs(t *testing.T) {
+	fields := make([]Field, 0, 10)
+	for i := 0; i < 10; i++ {
+		fields = append(fields, F("k"+strconv.Itoa(i), i))
+	}
+
+	logger := New("").WithEncoder(TextEncoder(DiscardWriter())).WithCtx(fields...)
+	if n := testing.AllocsPerRun(100, func() { logger.Info("msg") }); n != 0 && !raceEnabled {
+		t.Errorf("expect no allocation, but got %v", n)
+	}
+
+	// The cache is full, and the oldest entries are replaced.
+	encoders := make([]Encoder, maxCtxsCacheEntries+2)
+	for i := range encoders {
+		encoders[i] = JSONEncoder(DiscardWriter())
+		logger.Encoder = encoders[i]
+		logger.Info("msg")
+	}
+
+	entries := logger.ctxsCache.entries.Load().([]*encodedCtxs)
+	if len(entries) != maxCtxsCacheEntries {
+		t.Errorf("expect %d entries, but got %d", maxCtxsCacheEntries, len(entries))
+	}
+
+	// The latest encoder is cached.
+	if n := testing.AllocsPerRun(100, func() { logger.Info("msg") }); n != 0 && !raceEnabled {
+		t.Errorf("expect no allocation, but got %v", n)
+	}
+}
+
+func TestEncodeCtxsManyEncoders(t *testing.T) {
+	bufs := make([]*Builder, maxCtxsCacheEntries*2)
+	logger := New("").WithCtx(F("k1", "v1"), Int("k2", 2))
+	for i := 0; i < 3; i++ {
+		for j := range bufs {
+			if i == 0 {
+				bufs[j] = NewBuilder(128)
+			}
+
+			logger.Encoder = TextEncoder(StreamWriter(bufs[j]))
+			logger.Info("msg", F("k3", j))
+		}
+	}
+
+	for j, buf := range bufs {
+		line := "k1=v1 k2=2 k3=" + strconv.Itoa(j) + " msg=msg\n"
+		if expect := line + line + line; buf.String() != expect {
+			t.Errorf("%d: expect '%s', but got '%s'", j, expect, buf.String())
+		}
+	}
+}
+
+func TestEncodeCtxsZeroTime(t *testing.T) {
+	for _, field := range []Field{F("t", time.Time{}), Time("t", time.Time{})} {
+		if isStaticField(field) {
+			t.Errorf("the zero time field '%T' is static", field)
+		}
+	}
+
+	now := time.Now()
+	for _, field := range []Field{F("t", now), Time("t", now)} {
+		if !isStaticField(field) {
+			t.Errorf("the time field '%T' is not static", field)
+		}
+	}
+}
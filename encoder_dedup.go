@@ -0,0 +1,198 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DedupEncoder is an encoder to suppress the duplicate records, like syslogd.
+type DedupEncoder struct {
+	Encoder
+	window time.Duration
+
+	lock   sync.Mutex
+	last   []byte // The key of the last record
+	record Record // The last record to emit the summary
+	count  int    // The number of the suppressed repeats
+	start  time.Time
+	timer  *time.Timer
+	gen    uint64 // The generation of the timer
+}
+
+// NewDedupEncoder returns a new DedupEncoder, which suppresses the records
+// identical to the last one, that's, having the same level, message
+// and fields, within the window since the last one was encoded.
+//
+// When the window closes or a different record arrives, a summary record,
+// such as "last message repeated 10 times", will be emitted with the level
+// and the logger name of the last record if some repeats have been suppressed.
+//
+// The window is measured by the time of the records, which is the current
+// time if missing, but the summary is emitted by the timer in real time
+// if no record arrives after the window closes.
+//
+// Notice: the lazy fields will be evaluated only once to compare and encode
+// the records, and the stack fields, such as Caller, are ignored.
+func NewDedupEncoder(enc Encoder, window time.Duration) *DedupEncoder {
+	if window <= 0 {
+		panic("NewDedupEncoder: the window must be greater than 0")
+	}
+	return &DedupEncoder{Encoder: enc, window: window}
+}
+
+// Encode implements the interface Encoder.
+func (e *DedupEncoder) Encode(r Record) {
+	r.Depth++
+
+	// Evaluate the lazy fields only once for both the key and the encoder.
+	if ctxs, ok := resolveLazyFields(r.Ctxs); ok {
+		r.Ctxs, r.ctxsCache = ctxs, nil
+	}
+	r.Fields, _ = resolveLazyFields(r.Fields)
+
+	buf := getBuilder()
+	buf.AppendByte(byte(r.Lvl))
+	buf.AppendString(r.Name)
+	buf.AppendByte(0)
+	buf.AppendString(r.Msg)
+	appendDedupKey(buf, r.Ctxs)
+	appendDedupKey(buf, r.Fields)
+
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	e.lock.Lock()
+	if e.last != nil && string(e.last) == string(buf.Bytes()) &&
+		now.Sub(e.start) < e.window {
+		e.count++
+		if e.timer == nil {
+			gen := e.gen
+			e.timer = time.AfterFunc(e.start.Add(e.window).Sub(now),
+				func() { e.expire(gen) })
+		}
+		e.lock.Unlock()
+		putBuilder(buf)
+		return
+	}
+
+	summary, ok := e.flush(r.Time)
+	e.last = append(e.last[:0], buf.Bytes()...)
+	e.record = Record{Name: r.Name, Lvl: r.Lvl, Ctxs: r.Ctxs, Ctx: r.Ctx,
+		ctxsCache: r.ctxsCache}
+	e.start = now
+	e.lock.Unlock()
+	putBuilder(buf)
+
+	if ok {
+		e.Encoder.Encode(summary)
+	}
+	e.Encoder.Encode(r)
+}
+
+// Flush emits the summary record of the suppressed repeats if exist,
+// and forgets the last record.
+func (e *DedupEncoder) Flush() {
+	e.lock.Lock()
+	summary, ok := e.flush(time.Time{})
+	e.last = e.last[:0]
+	e.lock.Unlock()
+
+	if ok {
+		e.Encoder.Encode(summary)
+	}
+}
+
+func (e *DedupEncoder) expire(gen uint64) {
+	var ok bool
+	var summary Record
+	e.lock.Lock()
+	if e.gen == gen {
+		summary, ok = e.flush(time.Time{})
+		e.last = e.last[:0]
+	}
+	e.lock.Unlock()
+
+	if ok {
+		e.Encoder.Encode(summary)
+	}
+}
+
+// flush stops the timer and returns the summary record with the time,
+// which is the current time if zero. ok is false if no repeat is suppressed.
+//
+// The summary record should be encoded after releasing the lock.
+func (e *DedupEncoder) flush(now time.Time) (summary Record, ok bool) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+		e.gen++
+	}
+
+	if ok = e.count > 0; ok {
+		summary = e.record
+		summary.Time = now
+		summary.Msg = fmt.Sprintf("last message repeated %d times", e.count)
+		e.count = 0
+	}
+	return
+}
+
+// resolveLazyFields returns a copy of the fields with the lazy fields
+// evaluated, and false and the original fields if no lazy field exists.
+func resolveLazyFields(fields []Field) ([]Field, bool) {
+	var resolved []Field
+	for i, f := range fields {
+		if v, ok := f.(field); ok {
+			switch v.value.(type) {
+			case func() interface{}, func() string:
+				if resolved == nil {
+					resolved = append(make([]Field, 0, len(fields)), fields...)
+				}
+				resolved[i] = field{key: v.key, value: v.Value()}
+			}
+		}
+	}
+
+	if resolved == nil {
+		return fields, false
+	}
+	return resolved, true
+}
+
+func appendDedupKey(buf *Builder, fields []Field) {
+	for _, field := range fields {
+		if _, ok := field.(StackField); ok {
+			continue
+		}
+
+		buf.AppendByte(0)
+		buf.AppendString(field.Key())
+		buf.AppendByte('=')
+		switch v := field.Value().(type) {
+		case FieldError:
+			buf.AppendString(v.Error())
+			appendDedupKey(buf, v.Fields())
+		case FieldReleaser:
+			appendDedupKey(buf, v.Fields())
+		default:
+			buf.AppendAnyFmt(v)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDedupEncoder(t *testing.T) {
+	buf := NewBuilder(1024)
+	enc := NewDedupEncoder(TextEncoder(SafeWriter(StreamWriter(buf)),
+		Quote(), EncodeLevel("lvl")), time.Hour)
+	logger := New("").WithEncoder(enc)
+
+	for i := 0; i < 3; i++ {
+		logger.Error("retry", E(errors.New("error")), Caller("caller"))
+	}
+	logger.Error("retry", E(errors.New("another")))
+	logger.Error("retry", E(errors.New("another")))
+	logger.Warn("retry", E(errors.New("another")))
+	enc.Flush()
+
+	expect := `lvl=ERROR err=error caller=encoder_dedup_test.go:30 msg=retry
+lvl=ERROR msg="last message repeated 2 times"
+lvl=ERROR err=another msg=retry
+lvl=ERROR msg="last message repeated 1 times"
+lvl=WARN err=another msg=retry
+`
+	if s := buf.String(); s != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, s)
+	}
+
+	// The window is measured by the time of the records.
+	buf.Reset()
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	enc = NewDedupEncoder(TextEncoder(SafeWriter(StreamWriter(buf)), Quote(),
+		EncodeTime("t", time.RFC3339), EncodeLevel("lvl")), time.Hour)
+	logger = logger.WithEncoder(enc).WithClock(ClockFunc(func() time.Time { return now }))
+	logger.Info("msg")
+	logger.Info("msg")
+	now = now.Add(time.Hour)
+	logger.Info("msg")
+	enc.Flush()
+
+	expect = "t=2020-01-02T03:04:05Z lvl=INFO msg=msg\n" +
+		"t=2020-01-02T04:04:05Z lvl=INFO msg=\"last message repeated 1 times\"\n" +
+		"t=2020-01-02T04:04:05Z lvl=INFO msg=msg\n"
+	if s := buf.String(); s != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, s)
+	}
+}
+
+func TestDedupEncoderLazyAndCtxs(t *testing.T) {
+	var count int
+	lazy := F("n", func() interface{} { count++; return 1 })
+
+	buf := NewBuilder(1024)
+	enc := NewDedupEncoder(TextEncoder(StreamWriter(buf), EncodeLevel("lvl")), time.Hour)
+	logger := New("").WithEncoder(enc).WithCtx(F("req", 1), lazy)
+	for i := 0; i < 3; i++ {
+		logger.Info("msg", lazy)
+	}
+	enc.Flush()
+
+	expect := "lvl=INFO req=1 n=1 n=1 msg=msg\n" +
+		"lvl=INFO req=1 n=1 msg=last message repeated 2 times\n"
+	if s := buf.String(); s != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, s)
+	} else if count != 6 {
+		t.Errorf("expect the lazy fields to be evaluated 6 times, but got %d", count)
+	}
+}
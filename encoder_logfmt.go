@@ -0,0 +1,358 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LogfmtEncoder encodes the key-values log as the logfmt, which is like
+// TextEncoder but follows the logfmt rules strictly so that the line can
+// always be parsed back, for example, by ParseLogfmt:
+//
+//   - The value is quoted if it contains the space, '=', '"' or the control
+//     characters, and is escaped in the quotes like the JSON string.
+//   - The space, '=', '"' and the control characters in the key are replaced
+//     with '_', and the empty key is replaced with "_".
+//
+// The option Quote is ignored, and the message will use "msg" as the key.
+func LogfmtEncoder(w Writer, options ...EncoderOption) Encoder {
+	opt := getOption(options...)
+	encodeFields := func(buf *Builder, fs []Field, depth int, prefix string) (string, int) {
+		return logfmtEncodeFields(buf, fs, depth+1, opt.TimeFmt, prefix), 0
+	}
+
+	return EncoderFunc(w, func(buf *Builder, r Record) {
+		r.Depth++
+
+		// Time
+		if opt.TimeKey != "" {
+			appendLogfmtKey(buf, opt.TimeKey)
+			buf.WriteByte('=')
+			start := buf.Len()
+			encodeRecordTime(buf, r.Time, &opt)
+			logfmtQuoteValue(buf, start)
+			buf.WriteByte(' ')
+		}
+
+		// Logger Name
+		if r.Name != "" && opt.LoggerKey != "" {
+			appendLogfmtKey(buf, opt.LoggerKey)
+			buf.WriteByte('=')
+			appendLogfmtString(buf, r.Name)
+			buf.WriteByte(' ')
+		}
+
+		// Level
+		if opt.LevelKey != "" {
+			appendLogfmtKey(buf, opt.LevelKey)
+			buf.WriteByte('=')
+			buf.WriteString(r.Lvl.String())
+			buf.WriteByte(' ')
+		}
+
+		// Caller
+		if c := opt.getCaller(r, r.Depth); c != nil {
+			if opt.CallerKey != "" {
+				appendLogfmtKey(buf, opt.CallerKey)
+				buf.WriteByte('=')
+				appendLogfmtString(buf, c.path(opt.CallerMode))
+				buf.WriteByte(' ')
+			}
+			if opt.FuncKey != "" {
+				appendLogfmtKey(buf, opt.FuncKey)
+				buf.WriteByte('=')
+				appendLogfmtString(buf, c.function)
+				buf.WriteByte(' ')
+			}
+		}
+
+		// Ctxs and Fields
+		prefix, _ := encodeCtxs(buf, r, r.Depth, &opt, encodeFields)
+		logfmtEncodeFields(buf, r.Fields, r.Depth, opt.TimeFmt, prefix)
+
+		// Message
+		buf.WriteString("msg=")
+		appendLogfmtString(buf, r.Msg)
+
+		if opt.Newline {
+			buf.WriteByte('\n')
+		}
+	})
+}
+
+// logfmtEncodeFields is the same as textEncodeFields, but for logfmt.
+// The returned prefix has been sanitized.
+func logfmtEncodeFields(buf *Builder, fields []Field, depth int, timeFmt,
+	prefix string) string {
+	depth++
+	for _, field := range fields {
+		if f, ok := asTypedField(field); ok {
+			switch f.typ {
+			case namespaceType:
+				prefix = logfmtPrefix(prefix, f.key)
+				continue
+			case objectType:
+				obj, _ := f.any.(ObjectMarshaler)
+				logfmtEncodeObject(buf, prefix, f.key, obj, timeFmt)
+			case arrayType:
+				buf.WriteString(prefix)
+				appendLogfmtKey(buf, f.key)
+				buf.WriteByte('=')
+				logfmtEncodeValue(buf, prefix, f.key, f.any, timeFmt)
+			default:
+				buf.WriteString(prefix)
+				appendLogfmtKey(buf, f.key)
+				buf.WriteByte('=')
+				start := buf.Len()
+				textEncodeTypedField(buf, f, false, timeFmt)
+				logfmtQuoteValue(buf, start)
+			}
+			buf.WriteByte(' ')
+			continue
+		}
+
+		var value interface{}
+		if s, ok := field.(StackField); ok {
+			value = s.Stack(depth)
+		} else {
+			value = field.Value()
+		}
+
+		// The keys of the object are encoded as the dotted keys.
+		if obj, ok := value.(ObjectMarshaler); ok {
+			logfmtEncodeObject(buf, prefix, field.Key(), obj, timeFmt)
+			buf.WriteByte(' ')
+			continue
+		}
+
+		buf.WriteString(prefix)
+		appendLogfmtKey(buf, field.Key())
+		buf.WriteByte('=')
+
+		appendSpace := true
+		switch v := value.(type) {
+		case FieldError:
+			appendLogfmtString(buf, v.Error())
+			if fields := v.Fields(); len(fields) != 0 {
+				appendSpace = false
+				buf.WriteByte(' ')
+				prefix = logfmtEncodeFields(buf, fields, depth-1, timeFmt, prefix)
+			}
+			v.Release()
+		case FieldReleaser:
+			if fields := v.Fields(); len(fields) != 0 {
+				appendSpace = false
+				buf.WriteByte(' ')
+				prefix = logfmtEncodeFields(buf, fields, depth-1, timeFmt, prefix)
+			}
+			v.Release()
+		case string:
+			appendLogfmtString(buf, v)
+		default:
+			logfmtEncodeValue(buf, prefix, field.Key(), v, timeFmt)
+		}
+
+		if appendSpace {
+			buf.WriteByte(' ')
+		}
+	}
+	return prefix
+}
+
+// logfmtEncodeObject encodes the object with the dotted keys for logfmt.
+func logfmtEncodeObject(buf *Builder, prefix, key string, value ObjectMarshaler,
+	timeFmt string) {
+	e := getTextObjectEncoder(buf, false, timeFmt)
+	e.logfmt = true
+	e.prefix = append(e.prefix, prefix...)
+	if err := e.AddObject(key, value); err != nil {
+		e.key(key + "Error")
+		e.buf.WriteString(err.Error())
+	}
+	e.finish()
+	putTextObjectEncoder(e)
+}
+
+// logfmtEncodeValue encodes the value after "key=" for logfmt.
+func logfmtEncodeValue(buf *Builder, prefix, key string, value interface{},
+	timeFmt string) {
+	e := getTextObjectEncoder(buf, false, timeFmt)
+	e.logfmt = true
+	e.value = buf.Len()
+	if err := e.appendAny(value); err != nil {
+		e.space = true
+		e.prefix = append(e.prefix, prefix...)
+		e.key(key + "Error")
+		e.buf.WriteString(err.Error())
+	}
+	e.finish()
+	putTextObjectEncoder(e)
+}
+
+// logfmtPrefix returns the new dotted prefix for the namespace key.
+func logfmtPrefix(prefix, key string) string {
+	buf := getBuilder()
+	buf.WriteString(prefix)
+	appendLogfmtKey(buf, key)
+	buf.WriteByte('.')
+	prefix = buf.String()
+	putBuilder(buf)
+	return prefix
+}
+
+// isLogfmtSpecialRune reports whether the rune needs to be quoted in the value
+// or to be replaced in the key.
+func isLogfmtSpecialRune(r rune, size int) bool {
+	if r < utf8.RuneSelf {
+		return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+	}
+	return (r == utf8.RuneError && size == 1) || unicode.IsSpace(r) ||
+		unicode.IsControl(r)
+}
+
+// appendLogfmtKey appends the key, which replaces the space, '=', '"',
+// the control characters and the invalid UTF-8 bytes with '_'.
+func appendLogfmtKey(buf *Builder, key string) {
+	buf.buf = appendLogfmtKeyBytes(buf.buf, key)
+}
+
+func appendLogfmtKeyBytes(dst []byte, key string) []byte {
+	if key == "" {
+		return append(dst, '_')
+	}
+
+	for i := 0; i < len(key); {
+		r, n := rune(key[i]), 1
+		if r >= utf8.RuneSelf {
+			r, n = utf8.DecodeRuneInString(key[i:])
+		}
+
+		if isLogfmtSpecialRune(r, n) {
+			dst = append(dst, '_')
+		} else {
+			dst = append(dst, key[i:i+n]...)
+		}
+		i += n
+	}
+	return dst
+}
+
+// appendLogfmtString appends the string value, which is quoted if necessary.
+func appendLogfmtString(buf *Builder, s string) {
+	for i := 0; i < len(s); {
+		r, n := rune(s[i]), 1
+		if r >= utf8.RuneSelf {
+			r, n = utf8.DecodeRuneInString(s[i:])
+		}
+
+		if isLogfmtSpecialRune(r, n) {
+			buf.AppendJSONString(s)
+			return
+		}
+		i += n
+	}
+	buf.WriteString(s)
+}
+
+// logfmtQuoteValue quotes the value encoded from start if necessary.
+func logfmtQuoteValue(buf *Builder, start int) {
+	value := buf.buf[start:]
+	for i := 0; i < len(value); {
+		r, n := rune(value[i]), 1
+		if r >= utf8.RuneSelf {
+			r, n = utf8.DecodeRune(value[i:])
+		}
+
+		if isLogfmtSpecialRune(r, n) {
+			tmp := getBuilder()
+			tmp.AppendJSONBytes(value)
+			buf.buf = append(buf.buf[:start], tmp.buf...)
+			putBuilder(tmp)
+			return
+		}
+		i += n
+	}
+}
+
+// LogfmtPair is the key-value pair parsed from the logfmt line.
+type LogfmtPair struct {
+	Key   string
+	Value string
+}
+
+// ParseLogfmt parses the logfmt line, such as the line encoded
+// by LogfmtEncoder, and returns the key-value pairs in order.
+//
+// The value of the key without '=' is empty, and the quoted value
+// is unescaped.
+func ParseLogfmt(line string) (pairs []LogfmtPair, err error) {
+	for i := 0; i < len(line); {
+		if line[i] <= ' ' {
+			i++
+			continue
+		}
+
+		// Key
+		start := i
+		for ; i < len(line) && line[i] > ' ' && line[i] != '='; i++ {
+			if line[i] == '"' {
+				return nil, fmt.Errorf("invalid logfmt: unexpected '\"' in the key at %d", i)
+			}
+		}
+
+		key := line[start:i]
+		if i == len(line) || line[i] != '=' {
+			pairs = append(pairs, LogfmtPair{Key: key})
+			continue
+		} else if key == "" {
+			return nil, fmt.Errorf("invalid logfmt: missing the key at %d", i)
+		}
+
+		// Value
+		var value string
+		if i++; i < len(line) && line[i] == '"' {
+			start = i
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(line) {
+				return nil, fmt.Errorf("invalid logfmt: unterminated value at %d", start)
+			}
+
+			i++
+			if value, err = strconv.Unquote(line[start:i]); err != nil {
+				return nil, fmt.Errorf("invalid logfmt: invalid quoted value at %d", start)
+			} else if i < len(line) && line[i] > ' ' {
+				return nil, fmt.Errorf("invalid logfmt: unexpected '%c' at %d", line[i], i)
+			}
+		} else {
+			for start = i; i < len(line) && line[i] > ' '; i++ {
+				if line[i] == '"' || line[i] == '=' {
+					return nil, fmt.Errorf("invalid logfmt: unexpected '%c' at %d", line[i], i)
+				}
+			}
+			value = line[start:i]
+		}
+
+		pairs = append(pairs, LogfmtPair{Key: key, Value: value})
+	}
+	return
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	buf := NewBuilder(512)
+	logger := New("").WithEncoder(LogfmtEncoder(StreamWriter(buf)))
+	logger.WithCtx(F("a b", 1), F("", 2)).Info("a=b", F("k=\"v\"", `say "hi"`),
+		F("empty", ""), F("newline", "a\nb"), F("eq", "a=b"), F("path", `C:\dir`),
+		Str("ctrl", "a\x00b"), Bytes("tab", []byte("a\tb")), F("tags", []string{"a", "b"}),
+		Object("addr", testAddr{Host: "local host", Port: 80}),
+		Namespace("ns x"), Err("err", errors.New("failed: EOF")))
+
+	expect := `a_b=1 _=2 k__v_="say \"hi\"" empty= newline="a\nb" eq="a=b" ` +
+		`path=C:\dir ctrl="a\u0000b" tab="a\tb" tags="[a b]" addr.host="local host" ` +
+		`addr.port=80 ns_x.err="failed: EOF" msg="a=b"` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
+
+func TestLogfmtRoundTrip(t *testing.T) {
+	values := []string{
+		"", "abc", "a b", "a=b", `"`, `a"b`, `\`, `a\"b`, "a\nb\r\tc",
+		"\x00\x1f\x7f", "中文", "a\u00a0b", "a\u2028b", "\u0085", "=", "==",
+		"{}", "[a b]", "-", "a\\nb",
+	}
+
+	buf := NewBuilder(512)
+	logger := New("").WithEncoder(LogfmtEncoder(StreamWriter(buf), Newline(false)))
+	for _, value := range values {
+		buf.Reset()
+		logger.Info(value, F(value, value))
+
+		pairs, err := ParseLogfmt(buf.String())
+		if err != nil {
+			t.Errorf("%q: %s: %s", value, buf.String(), err)
+			continue
+		} else if len(pairs) != 2 {
+			t.Errorf("%q: expect 2 pairs, but got %d: %s", value, len(pairs), buf.String())
+			continue
+		}
+
+		if pairs[1].Key != "msg" || pairs[1].Value != value {
+			t.Errorf("expect msg %q, but got %q", value, pairs[1].Value)
+		}
+		if pairs[0].Value != value {
+			t.Errorf("expect the value %q, but got %q", value, pairs[0].Value)
+		}
+		if key := pairs[0].Key; key == "" || strings.ContainsAny(key, " =\"\n") {
+			t.Errorf("unexpected key %q", key)
+		}
+	}
+
+	// Time with the space
+	buf.Reset()
+	logger.Encoder = LogfmtEncoder(StreamWriter(buf), EncodeTime("t", "2006-01-02 15:04:05"))
+	logger.Info("msg", Time("time", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)))
+	if pairs, err := ParseLogfmt(buf.String()); err != nil {
+		t.Error(err)
+	} else if len(pairs) != 3 || pairs[1].Value != "2020-01-02 03:04:05" {
+		t.Errorf("unexpected the time: %s", buf.String())
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	pairs, err := ParseLogfmt(` a=1  b="x y" c d=  e="\u00e9\"" `)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expects := []LogfmtPair{{"a", "1"}, {"b", "x y"}, {"c", ""}, {"d", ""}, {"e", `é"`}}
+	if len(pairs) != len(expects) {
+		t.Fatalf("expect %d pairs, but got %d", len(expects), len(pairs))
+	}
+	for i, pair := range pairs {
+		if pair != expects[i] {
+			t.Errorf("%d: expect %v, but got %v", i, expects[i], pair)
+		}
+	}
+
+	for _, line := range []string{`=1`, `a"=1`, `a="1`, `a="1"b`, `a=b=c`, `a=b"c`} {
+		if _, err := ParseLogfmt(line); err == nil {
+			t.Errorf("expect an error for '%s'", line)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RateLimit is the limit of the token bucket.
+type RateLimit struct {
+	Rate  float64 // The number of the records allowed per second
+	Burst int     // The maximum number of the records allowed at once
+}
+
+// RateLimitStats is the statistics of a token bucket.
+type RateLimitStats struct {
+	Level   Level
+	Caller  string // The call site as "file:line", which is empty if not per call site
+	Allowed uint64
+	Dropped uint64
+}
+
+type rateBucket struct {
+	lock    sync.Mutex
+	limit   RateLimit
+	tokens  float64
+	last    time.Time
+	allowed uint64
+	dropped uint64
+}
+
+func newRateBucket(limit RateLimit, now time.Time) *rateBucket {
+	return &rateBucket{limit: limit, tokens: float64(limit.Burst), last: now}
+}
+
+func (b *rateBucket) allow(now time.Time) (ok bool) {
+	b.lock.Lock()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.limit.Rate
+		if burst := float64(b.limit.Burst); b.tokens > burst {
+			b.tokens = burst
+		}
+		b.last = now
+	}
+
+	if ok = b.tokens >= 1; ok {
+		b.tokens--
+		b.allowed++
+	} else {
+		b.dropped++
+	}
+	b.lock.Unlock()
+	return
+}
+
+type rateKey struct {
+	lvl Level
+	pc  uintptr
+}
+
+// RateLimitEncoder is an encoder to limit the rate of the records
+// by the token buckets, which is thread-safe.
+type RateLimitEncoder struct {
+	Encoder
+	limits      map[Level]RateLimit
+	perCallSite bool
+
+	lock    sync.RWMutex
+	buckets map[rateKey]*rateBucket
+}
+
+// NewRateLimitEncoder returns a new RateLimitEncoder, which limits the rate
+// of the records by the token bucket configured by the level in limits.
+// The records whose level is not in limits are not limited.
+//
+// If perCallSite is true, every call site, that's, the program counter
+// of the caller, has its own token bucket for each level, so a hot call site
+// cannot starve the others. The program counter is taken from Record.PC
+// if it is set.
+func NewRateLimitEncoder(enc Encoder, limits map[Level]RateLimit, perCallSite bool) *RateLimitEncoder {
+	_limits := make(map[Level]RateLimit, len(limits))
+	for lvl, limit := range limits {
+		if limit.Rate <= 0 || limit.Burst < 1 {
+			panic(fmt.Errorf("NewRateLimitEncoder: invalid rate limit %+v for %s", limit, lvl))
+		}
+		_limits[lvl] = limit
+	}
+
+	return &RateLimitEncoder{
+		Encoder:     enc,
+		limits:      _limits,
+		perCallSite: perCallSite,
+		buckets:     make(map[rateKey]*rateBucket, 16),
+	}
+}
+
+// Encode implements the interface Encoder.
+func (e *RateLimitEncoder) Encode(r Record) {
+	r.Depth++
+
+	limit, ok := e.limits[r.Lvl]
+	if !ok {
+		e.Encoder.Encode(r)
+		return
+	}
+
+	key := rateKey{lvl: r.Lvl}
+	if e.perCallSite {
+		if key.pc = r.PC; key.pc == 0 {
+			key.pc = callerPC(r.Depth)
+		}
+	}
+
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if e.getBucket(key, limit, now).allow(now) {
+		e.Encoder.Encode(r)
+	}
+}
+
+func (e *RateLimitEncoder) getBucket(key rateKey, limit RateLimit, now time.Time) *rateBucket {
+	e.lock.RLock()
+	bucket, ok := e.buckets[key]
+	e.lock.RUnlock()
+	if ok {
+		return bucket
+	}
+
+	e.lock.Lock()
+	if bucket, ok = e.buckets[key]; !ok {
+		bucket = newRateBucket(limit, now)
+		e.buckets[key] = bucket
+	}
+	e.lock.Unlock()
+	return bucket
+}
+
+// Dropped returns the total number of the dropped records with the level.
+func (e *RateLimitEncoder) Dropped(lvl Level) (dropped uint64) {
+	for _, stats := range e.Stats() {
+		if stats.Level == lvl {
+			dropped += stats.Dropped
+		}
+	}
+	return
+}
+
+// Stats returns the statistics of all the token buckets,
+// which are sorted by the level and the caller.
+func (e *RateLimitEncoder) Stats() []RateLimitStats {
+	e.lock.RLock()
+	stats := make([]RateLimitStats, 0, len(e.buckets))
+	for key, bucket := range e.buckets {
+		bucket.lock.Lock()
+		s := RateLimitStats{
+			Level:   key.lvl,
+			Allowed: bucket.allowed,
+			Dropped: bucket.dropped,
+		}
+		bucket.lock.Unlock()
+
+		if c := lookupCaller(key.pc); c != nil {
+			s.Caller = c.path(CallerFullPath)
+		}
+		stats = append(stats, s)
+	}
+	e.lock.RUnlock()
+
+	sort.Sort(rateLimitStats(stats))
+	return stats
+}
+
+type rateLimitStats []RateLimitStats
+
+func (s rateLimitStats) Len() int      { return len(s) }
+func (s rateLimitStats) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s rateLimitStats) Less(i, j int) bool {
+	if s[i].Level != s[j].Level {
+		return s[i].Level < s[j].Level
+	}
+	return s[i].Caller < s[j].Caller
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRateLimitEncoder(t *testing.T) {
+	limits := map[Level]RateLimit{LvlInfo: {Rate: 0.001, Burst: 2}}
+
+	buf := NewBuilder(1024)
+	enc := NewRateLimitEncoder(TextEncoder(StreamWriter(buf)), limits, false)
+	logger := New("").WithEncoder(enc)
+	for i := 0; i < 5; i++ {
+		logger.Info("info1")
+		logger.Info("info2")
+		logger.Warn("warn")
+	}
+
+	if n := strings.Count(buf.String(), "info"); n != 2 {
+		t.Errorf("expect 2 info logs, but got %d", n)
+	}
+	if n := strings.Count(buf.String(), "warn"); n != 5 {
+		t.Errorf("expect 5 warn logs, but got %d", n)
+	}
+	if n := enc.Dropped(LvlInfo); n != 8 {
+		t.Errorf("expect 8 dropped, but got %d", n)
+	}
+
+	buf.Reset()
+	enc = NewRateLimitEncoder(enc.Encoder, limits, true)
+	logger = logger.WithEncoder(enc)
+	for i := 0; i < 5; i++ {
+		logger.Info("info1")
+		logger.Info("info2")
+	}
+
+	if n := strings.Count(buf.String(), "info"); n != 4 {
+		t.Errorf("expect 4 info logs, but got %d", n)
+	}
+
+	stats := enc.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("expect 2 stats, but got %d", len(stats))
+	}
+	for i, line := range []string{"49", "50"} {
+		s := stats[i]
+		if caller := filepath.Base(s.Caller); caller != "encoder_ratelimit_test.go:"+line {
+			t.Errorf("unexpected caller '%s'", caller)
+		} else if s.Level != LvlInfo || s.Allowed != 2 || s.Dropped != 3 {
+			t.Errorf("unexpected stats %+v", s)
+		}
+	}
+}
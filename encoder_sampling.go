@@ -0,0 +1,170 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	sampleLevels   = int(LvlFatal)
+	sampleCounters = 1024
+)
+
+type sampleCounter struct {
+	resetAt int64
+	count   uint64
+}
+
+func (c *sampleCounter) incr(now int64, interval time.Duration) uint64 {
+	resetAt := atomic.LoadInt64(&c.resetAt)
+	if resetAt > now {
+		return atomic.AddUint64(&c.count, 1)
+	}
+
+	// Only the goroutine winning the tick resets the counter.
+	if atomic.CompareAndSwapInt64(&c.resetAt, resetAt, now+int64(interval)) {
+		atomic.StoreUint64(&c.count, 1)
+		return 1
+	}
+
+	// Another goroutine has reset the counter.
+	return atomic.AddUint64(&c.count, 1)
+}
+
+// SamplingEncoder is an encoder to sample the log records to bound the volume
+// of the logs under load, which is like the sampler of zap.
+type SamplingEncoder struct {
+	// Put the 64-bit fields first to keep them aligned for atomic operations.
+	counters  [sampleLevels][sampleCounters]sampleCounter
+	dropped   [sampleLevels]uint64
+	summaryAt int64
+
+	Encoder
+	interval   time.Duration
+	first      uint64
+	thereafter uint64
+}
+
+// NewSamplingEncoder returns a new SamplingEncoder, which will encode
+// the first first records with the same level and message in each interval,
+// then every thereafter-th record, and drop the rest. If thereafter is 0,
+// all the rest in the interval will be dropped.
+//
+// The records are grouped by the level and the hash of the message, so
+// the different messages may share the same counter rarely. The PANIC
+// and FATAL logs are never dropped.
+//
+// When a record is encoded after an interval has elapsed, a WARN summary
+// record, such as "dropped=100 dropped_info=100 msg=...", will be emitted
+// first if some records have been dropped during the last intervals.
+//
+// It is lock-free to sample the records.
+func NewSamplingEncoder(enc Encoder, interval time.Duration, first, thereafter int) *SamplingEncoder {
+	if interval <= 0 {
+		panic("NewSamplingEncoder: the interval must be greater than 0")
+	} else if first < 0 || thereafter < 0 {
+		panic("NewSamplingEncoder: first and thereafter must not be negative")
+	}
+
+	return &SamplingEncoder{
+		Encoder:    enc,
+		interval:   interval,
+		first:      uint64(first),
+		thereafter: uint64(thereafter),
+		summaryAt:  time.Now().Add(interval).UnixNano(),
+	}
+}
+
+// Dropped returns the number of the dropped records by the level,
+// which have not been reported by the summary record.
+func (e *SamplingEncoder) Dropped(lvl Level) uint64 {
+	if int(lvl) < sampleLevels {
+		return atomic.LoadUint64(&e.dropped[lvl])
+	}
+	return 0
+}
+
+// Encode implements the interface Encoder.
+func (e *SamplingEncoder) Encode(r Record) {
+	r.Depth++
+
+	var now int64
+	if r.Time.IsZero() {
+		now = time.Now().UnixNano()
+	} else {
+		now = r.Time.UnixNano()
+	}
+
+	e.summary(r, now)
+	if int(r.Lvl) < sampleLevels {
+		c := &e.counters[r.Lvl][fnv32a(r.Msg)%sampleCounters]
+		if n := c.incr(now, e.interval); n > e.first &&
+			(e.thereafter == 0 || (n-e.first)%e.thereafter != 0) {
+			atomic.AddUint64(&e.dropped[r.Lvl], 1)
+			return
+		}
+	}
+
+	e.Encoder.Encode(r)
+}
+
+func (e *SamplingEncoder) summary(r Record, now int64) {
+	summaryAt := atomic.LoadInt64(&e.summaryAt)
+	if summaryAt > now || !atomic.CompareAndSwapInt64(&e.summaryAt,
+		summaryAt, now+int64(e.interval)) {
+		return
+	}
+
+	var total uint64
+	var fields []Field
+	for lvl := range e.dropped {
+		if n := atomic.SwapUint64(&e.dropped[lvl], 0); n > 0 {
+			key := "dropped_" + strings.ToLower(Level(lvl).String())
+			fields = append(fields, F(key, n))
+			total += n
+		}
+	}
+
+	if total > 0 {
+		fields = append([]Field{F("dropped", total)}, fields...)
+		e.Encoder.Encode(Record{
+			Name:   r.Name,
+			Time:   r.Time,
+			Depth:  r.Depth + 1,
+			Lvl:    LvlWarn,
+			Msg:    "sampling dropped the logs",
+			Fields: fields,
+			Ctx:    r.Ctx,
+		})
+	}
+}
+
+// fnv32a returns the FNV-1a hash of s without allocation.
+func fnv32a(s string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+
+	hash := uint32(offset32)
+	for i := 0; i < len(s); i++ {
+		hash ^= uint32(s[i])
+		hash *= prime32
+	}
+	return hash
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingEncoder(t *testing.T) {
+	buf := NewBuilder(1024)
+	enc := NewSamplingEncoder(TextEncoder(StreamWriter(buf), Quote()), time.Second, 2, 3)
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		enc.Encode(Record{Time: now, Lvl: LvlInfo, Msg: "msg1", Fields: []Field{F("i", i)}})
+	}
+	enc.Encode(Record{Time: now, Lvl: LvlError, Msg: "msg1"})
+	enc.Encode(Record{Time: now, Lvl: LvlInfo, Msg: "msg2"})
+	for i := 0; i < 3; i++ {
+		enc.Encode(Record{Time: now, Lvl: LvlFatal, Msg: "msg1"})
+	}
+
+	expects := []string{
+		"i=0 msg=msg1", "i=1 msg=msg1", "i=4 msg=msg1", "i=7 msg=msg1",
+		"msg=msg1", "msg=msg2", "msg=msg1", "msg=msg1", "msg=msg1", "",
+	}
+	if s := buf.String(); s != strings.Join(expects, "\n") {
+		t.Errorf("unexpected logs: %s", s)
+	}
+	if n := enc.Dropped(LvlInfo); n != 6 {
+		t.Errorf("expect 6 dropped, but got %d", n)
+	}
+
+	buf.Reset()
+	now = now.Add(time.Second)
+	enc.Encode(Record{Time: now, Lvl: LvlInfo, Msg: "msg1"})
+	expect := "dropped=6 dropped_info=6 msg=\"sampling dropped the logs\"\nmsg=msg1\n"
+	if s := buf.String(); s != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, s)
+	}
+	if n := enc.Dropped(LvlInfo); n != 0 {
+		t.Errorf("expect 0 dropped, but got %d", n)
+	}
+}
+
+func TestSampleCounter(t *testing.T) {
+	var c sampleCounter
+	now := time.Now().UnixNano()
+	for i := uint64(1); i <= 3; i++ {
+		if n := c.incr(now, time.Second); n != i {
+			t.Errorf("expect %d, but got %d", i, n)
+		}
+	}
+
+	// The counter is not reset until the tick.
+	if n := c.incr(now+int64(time.Second)-1, time.Second); n != 4 {
+		t.Errorf("expect 4, but got %d", n)
+	} else if n := c.incr(now+int64(time.Second), time.Second); n != 1 {
+		t.Errorf("expect 1, but got %d", n)
+	} else if c.resetAt != now+int64(time.Second)*2 {
+		t.Errorf("unexpected the next tick %d", c.resetAt-now)
+	}
+}
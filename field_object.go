@@ -0,0 +1,484 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"sync"
+	"time"
+)
+
+// ObjectMarshaler is used to encode the type itself as an object
+// by ObjectEncoder without the reflection.
+//
+// JSONEncoder encodes it as the nested JSON object, and TextEncoder encodes
+// its keys as the dotted keys, such as "key.subkey=value".
+type ObjectMarshaler interface {
+	MarshalLogObject(ObjectEncoder) error
+}
+
+// ObjectMarshalerFunc is a function to implement the interface ObjectMarshaler.
+type ObjectMarshalerFunc func(ObjectEncoder) error
+
+// MarshalLogObject implements the interface ObjectMarshaler.
+func (f ObjectMarshalerFunc) MarshalLogObject(enc ObjectEncoder) error {
+	return f(enc)
+}
+
+// ArrayMarshaler is used to encode the type itself as an array
+// by ArrayEncoder without the reflection.
+//
+// JSONEncoder encodes it as the JSON array, and TextEncoder encodes it
+// as "key=[elem1 elem2]".
+type ArrayMarshaler interface {
+	MarshalLogArray(ArrayEncoder) error
+}
+
+// ArrayMarshalerFunc is a function to implement the interface ArrayMarshaler.
+type ArrayMarshalerFunc func(ArrayEncoder) error
+
+// MarshalLogArray implements the interface ArrayMarshaler.
+func (f ArrayMarshalerFunc) MarshalLogArray(enc ArrayEncoder) error {
+	return f(enc)
+}
+
+// ObjectEncoder is used to add the key-values into the object.
+type ObjectEncoder interface {
+	AddString(key, value string)
+	AddInt(key string, value int)
+	AddInt64(key string, value int64)
+	AddUint64(key string, value uint64)
+	AddFloat64(key string, value float64)
+	AddBool(key string, value bool)
+	AddDuration(key string, value time.Duration)
+	AddTime(key string, value time.Time)
+	AddObject(key string, value ObjectMarshaler) error
+	AddArray(key string, value ArrayMarshaler) error
+
+	// AddAny adds the value of any type, which may use the reflection.
+	AddAny(key string, value interface{}) error
+}
+
+// ArrayEncoder is used to append the elements into the array.
+type ArrayEncoder interface {
+	AppendString(value string)
+	AppendInt(value int)
+	AppendInt64(value int64)
+	AppendUint64(value uint64)
+	AppendFloat64(value float64)
+	AppendBool(value bool)
+	AppendDuration(value time.Duration)
+	AppendTime(value time.Time)
+	AppendObject(value ObjectMarshaler) error
+	AppendArray(value ArrayMarshaler) error
+
+	// AppendAny appends the value of any type, which may use the reflection.
+	AppendAny(value interface{}) error
+}
+
+// Object returns a new Field with the value implementing ObjectMarshaler.
+//
+// If the value fails to be marshaled, the error will be encoded
+// with the key "<key>Error".
+func Object(key string, value ObjectMarshaler) Field {
+	return typedField{key: key, typ: objectType, any: value}
+}
+
+// Array returns a new Field with the value implementing ArrayMarshaler.
+//
+// If the value fails to be marshaled, the error will be encoded
+// with the key "<key>Error".
+func Array(key string, value ArrayMarshaler) Field {
+	return typedField{key: key, typ: arrayType, any: value}
+}
+
+// Namespace returns a new namespace Field, which nests the subsequent fields,
+// including the fields of the log if it is in the contexts of the logger,
+// under the key.
+//
+// JSONEncoder encodes the subsequent fields as the nested JSON object,
+// such as {"http":{"method":"GET"}}, and TextEncoder prefixes the keys
+// with the key and ".", such as "http.method=GET".
+func Namespace(key string) Field {
+	return typedField{key: key, typ: namespaceType}
+}
+
+// hasNamespace reports whether the fields contain the namespace field.
+func hasNamespace(fields []Field) bool {
+	for _, field := range fields {
+		if f, ok := asTypedField(field); ok && f.typ == namespaceType {
+			return true
+		}
+	}
+	return false
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+var jsonObjectEncoderPool = sync.Pool{New: func() interface{} {
+	return new(jsonObjectEncoder)
+}}
+
+// jsonObjectEncoder implements both ObjectEncoder and ArrayEncoder for JSON.
+type jsonObjectEncoder struct {
+	buf     *Builder
+	timeFmt string
+	comma   bool
+}
+
+func jsonEncodeValue(buf *Builder, key string, value interface{}, timeFmt string) {
+	e := jsonObjectEncoderPool.Get().(*jsonObjectEncoder)
+	e.buf, e.timeFmt, e.comma = buf, timeFmt, false
+	if err := e.appendAny(value); err != nil {
+		buf.WriteByte(',')
+		buf.AppendJSONString(key + "Error")
+		buf.WriteByte(':')
+		buf.AppendJSONString(err.Error())
+	}
+	e.buf = nil
+	jsonObjectEncoderPool.Put(e)
+}
+
+func (e *jsonObjectEncoder) key(key string) {
+	e.elem()
+	e.buf.AppendJSONString(key)
+	e.buf.WriteByte(':')
+}
+
+func (e *jsonObjectEncoder) elem() {
+	if e.comma {
+		e.buf.WriteByte(',')
+	}
+	e.comma = true
+}
+
+func (e *jsonObjectEncoder) appendObject(value ObjectMarshaler) (err error) {
+	if value == nil {
+		e.buf.WriteString("null")
+		return
+	}
+
+	e.buf.WriteByte('{')
+	e.comma = false
+	err = value.MarshalLogObject(e)
+	e.comma = true
+	e.buf.WriteByte('}')
+	return
+}
+
+func (e *jsonObjectEncoder) appendArray(value ArrayMarshaler) (err error) {
+	if value == nil {
+		e.buf.WriteString("null")
+		return
+	}
+
+	e.buf.WriteByte('[')
+	e.comma = false
+	err = value.MarshalLogArray(e)
+	e.comma = true
+	e.buf.WriteByte(']')
+	return
+}
+
+func (e *jsonObjectEncoder) appendTime(value time.Time) {
+	e.buf.WriteByte('"')
+	encodeTime(e.buf, value, e.timeFmt)
+	e.buf.WriteByte('"')
+}
+
+func (e *jsonObjectEncoder) appendDuration(value time.Duration) {
+	e.buf.WriteByte('"')
+	appendDuration(e.buf, value)
+	e.buf.WriteByte('"')
+}
+
+func (e *jsonObjectEncoder) appendAny(value interface{}) error {
+	switch v := value.(type) {
+	case ObjectMarshaler:
+		return e.appendObject(v)
+	case ArrayMarshaler:
+		return e.appendArray(v)
+	case time.Time:
+		e.appendTime(v)
+	case time.Duration:
+		e.appendDuration(v)
+	default:
+		return e.buf.AppendJSON(v)
+	}
+	return nil
+}
+
+func (e *jsonObjectEncoder) AddString(k, v string)          { e.key(k); e.buf.AppendJSONString(v) }
+func (e *jsonObjectEncoder) AddInt(k string, v int)         { e.key(k); e.buf.AppendInt(int64(v)) }
+func (e *jsonObjectEncoder) AddInt64(k string, v int64)     { e.key(k); e.buf.AppendInt(v) }
+func (e *jsonObjectEncoder) AddUint64(k string, v uint64)   { e.key(k); e.buf.AppendUint(v) }
+func (e *jsonObjectEncoder) AddFloat64(k string, v float64) { e.key(k); e.buf.AppendFloat(v, 64) }
+func (e *jsonObjectEncoder) AddBool(k string, v bool)       { e.key(k); e.buf.AppendBool(v) }
+func (e *jsonObjectEncoder) AddTime(k string, v time.Time)  { e.key(k); e.appendTime(v) }
+func (e *jsonObjectEncoder) AddDuration(k string, v time.Duration) {
+	e.key(k)
+	e.appendDuration(v)
+}
+func (e *jsonObjectEncoder) AddObject(k string, v ObjectMarshaler) error {
+	e.key(k)
+	return e.appendObject(v)
+}
+func (e *jsonObjectEncoder) AddArray(k string, v ArrayMarshaler) error {
+	e.key(k)
+	return e.appendArray(v)
+}
+func (e *jsonObjectEncoder) AddAny(k string, v interface{}) error {
+	e.key(k)
+	return e.appendAny(v)
+}
+
+func (e *jsonObjectEncoder) AppendString(v string)          { e.elem(); e.buf.AppendJSONString(v) }
+func (e *jsonObjectEncoder) AppendInt(v int)                { e.elem(); e.buf.AppendInt(int64(v)) }
+func (e *jsonObjectEncoder) AppendInt64(v int64)            { e.elem(); e.buf.AppendInt(v) }
+func (e *jsonObjectEncoder) AppendUint64(v uint64)          { e.elem(); e.buf.AppendUint(v) }
+func (e *jsonObjectEncoder) AppendFloat64(v float64)        { e.elem(); e.buf.AppendFloat(v, 64) }
+func (e *jsonObjectEncoder) AppendBool(v bool)              { e.elem(); e.buf.AppendBool(v) }
+func (e *jsonObjectEncoder) AppendTime(v time.Time)         { e.elem(); e.appendTime(v) }
+func (e *jsonObjectEncoder) AppendDuration(v time.Duration) { e.elem(); e.appendDuration(v) }
+func (e *jsonObjectEncoder) AppendObject(v ObjectMarshaler) error {
+	e.elem()
+	return e.appendObject(v)
+}
+func (e *jsonObjectEncoder) AppendArray(v ArrayMarshaler) error {
+	e.elem()
+	return e.appendArray(v)
+}
+func (e *jsonObjectEncoder) AppendAny(v interface{}) error {
+	e.elem()
+	return e.appendAny(v)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+var textObjectEncoderPool = sync.Pool{New: func() interface{} {
+	return &textObjectEncoder{prefix: make([]byte, 0, 64)}
+}}
+
+// textObjectEncoder implements both ObjectEncoder and ArrayEncoder for text.
+//
+// The keys of the object are qualified by the dotted prefix, such as
+// "key.subkey=value", and the object in the array is enclosed by the braces,
+// such as "key=[{subkey=value}]".
+type textObjectEncoder struct {
+	buf     *Builder
+	quote   bool
+	timeFmt string
+
+	prefix []byte // The dotted prefix of the keys, such as "key.subkey."
+	start  int    // The start of the prefix in the current braces
+	space  bool
+
+	// For logfmt, the keys are sanitized and the value of each top-level key
+	// is quoted as a whole if necessary after it has been encoded.
+	logfmt bool
+	nested int // The depth of the braces
+	value  int // The start of the unfinished value, or -1
+}
+
+func getTextObjectEncoder(buf *Builder, quote bool, timeFmt string) *textObjectEncoder {
+	e := textObjectEncoderPool.Get().(*textObjectEncoder)
+	e.buf, e.quote, e.timeFmt = buf, quote, timeFmt
+	e.prefix, e.start, e.space = e.prefix[:0], 0, false
+	e.logfmt, e.nested, e.value = false, 0, -1
+	return e
+}
+
+func putTextObjectEncoder(e *textObjectEncoder) {
+	e.buf = nil
+	textObjectEncoderPool.Put(e)
+}
+
+// textEncodeObject encodes the object with the dotted keys.
+func textEncodeObject(buf *Builder, key string, value ObjectMarshaler, quote bool, timeFmt string) {
+	e := getTextObjectEncoder(buf, quote, timeFmt)
+	if err := e.AddObject(key, value); err != nil {
+		e.key(key + "Error")
+		appendString(buf, err.Error(), quote)
+	}
+	putTextObjectEncoder(e)
+}
+
+// textEncodeValue encodes the value, which is the array generally, after "key=".
+func textEncodeValue(buf *Builder, key string, value interface{}, quote bool, timeFmt string) {
+	e := getTextObjectEncoder(buf, quote, timeFmt)
+	if err := e.appendAny(value); err != nil {
+		e.space = true
+		e.key(key + "Error")
+		appendString(buf, err.Error(), quote)
+	}
+	putTextObjectEncoder(e)
+}
+
+func (e *textObjectEncoder) key(key string) {
+	if !e.logfmt {
+		e.elem()
+		e.buf.Write(e.prefix[e.start:])
+		e.buf.WriteString(key)
+		e.buf.WriteByte('=')
+		return
+	}
+
+	if e.nested == 0 {
+		e.finish()
+	}
+	e.elem()
+	e.buf.Write(e.prefix[e.start:])
+	appendLogfmtKey(e.buf, key)
+	e.buf.WriteByte('=')
+	if e.nested == 0 {
+		e.value = e.buf.Len()
+	}
+}
+
+// finish quotes the unfinished value for logfmt.
+func (e *textObjectEncoder) finish() {
+	if e.value > -1 {
+		logfmtQuoteValue(e.buf, e.value)
+		e.value = -1
+	}
+}
+
+func (e *textObjectEncoder) elem() {
+	if e.space {
+		e.buf.WriteByte(' ')
+	}
+	e.space = true
+}
+
+func (e *textObjectEncoder) appendObject(value ObjectMarshaler) (err error) {
+	if value == nil {
+		e.buf.AppendAny(nil)
+		return
+	}
+
+	start := e.start
+	e.start = len(e.prefix)
+	e.buf.WriteByte('{')
+	e.space = false
+	e.nested++
+	err = value.MarshalLogObject(e)
+	e.nested--
+	e.space = true
+	e.buf.WriteByte('}')
+	e.start = start
+	return
+}
+
+func (e *textObjectEncoder) appendArray(value ArrayMarshaler) (err error) {
+	if value == nil {
+		e.buf.AppendAny(nil)
+		return
+	}
+
+	e.buf.WriteByte('[')
+	e.space = false
+	e.nested++
+	err = value.MarshalLogArray(e)
+	e.nested--
+	e.space = true
+	e.buf.WriteByte(']')
+	return
+}
+
+func (e *textObjectEncoder) appendAny(value interface{}) error {
+	switch v := value.(type) {
+	case ObjectMarshaler:
+		return e.appendObject(v)
+	case ArrayMarshaler:
+		return e.appendArray(v)
+	case string:
+		appendString(e.buf, v, e.quote)
+	case time.Time:
+		encodeTime(e.buf, v, e.timeFmt)
+	case time.Duration:
+		appendDuration(e.buf, v)
+	case error:
+		appendString(e.buf, v.Error(), e.quote)
+	default:
+		return e.buf.AppendAnyFmt(v)
+	}
+	return nil
+}
+
+func (e *textObjectEncoder) AddString(k, v string)          { e.key(k); appendString(e.buf, v, e.quote) }
+func (e *textObjectEncoder) AddInt(k string, v int)         { e.key(k); e.buf.AppendInt(int64(v)) }
+func (e *textObjectEncoder) AddInt64(k string, v int64)     { e.key(k); e.buf.AppendInt(v) }
+func (e *textObjectEncoder) AddUint64(k string, v uint64)   { e.key(k); e.buf.AppendUint(v) }
+func (e *textObjectEncoder) AddFloat64(k string, v float64) { e.key(k); e.buf.AppendFloat(v, 64) }
+func (e *textObjectEncoder) AddBool(k string, v bool)       { e.key(k); e.buf.AppendBool(v) }
+func (e *textObjectEncoder) AddTime(k string, v time.Time)  { e.key(k); encodeTime(e.buf, v, e.timeFmt) }
+func (e *textObjectEncoder) AddDuration(k string, v time.Duration) {
+	e.key(k)
+	appendDuration(e.buf, v)
+}
+func (e *textObjectEncoder) AddObject(k string, v ObjectMarshaler) (err error) {
+	if v == nil {
+		e.key(k)
+		e.buf.AppendAny(nil)
+		return
+	}
+
+	// Encode the keys of the object as the dotted keys.
+	prefix := len(e.prefix)
+	if e.logfmt {
+		e.prefix = append(appendLogfmtKeyBytes(e.prefix, k), '.')
+	} else {
+		e.prefix = append(append(e.prefix, k...), '.')
+	}
+	n := e.buf.Len()
+	err = v.MarshalLogObject(e)
+	e.prefix = e.prefix[:prefix]
+	if e.buf.Len() == n { // The empty object
+		e.key(k)
+		e.buf.WriteString("{}")
+	}
+	return
+}
+func (e *textObjectEncoder) AddArray(k string, v ArrayMarshaler) error {
+	e.key(k)
+	return e.appendArray(v)
+}
+func (e *textObjectEncoder) AddAny(k string, v interface{}) error {
+	if o, ok := v.(ObjectMarshaler); ok {
+		return e.AddObject(k, o)
+	}
+	e.key(k)
+	return e.appendAny(v)
+}
+
+func (e *textObjectEncoder) AppendString(v string)          { e.elem(); appendString(e.buf, v, e.quote) }
+func (e *textObjectEncoder) AppendInt(v int)                { e.elem(); e.buf.AppendInt(int64(v)) }
+func (e *textObjectEncoder) AppendInt64(v int64)            { e.elem(); e.buf.AppendInt(v) }
+func (e *textObjectEncoder) AppendUint64(v uint64)          { e.elem(); e.buf.AppendUint(v) }
+func (e *textObjectEncoder) AppendFloat64(v float64)        { e.elem(); e.buf.AppendFloat(v, 64) }
+func (e *textObjectEncoder) AppendBool(v bool)              { e.elem(); e.buf.AppendBool(v) }
+func (e *textObjectEncoder) AppendTime(v time.Time)         { e.elem(); encodeTime(e.buf, v, e.timeFmt) }
+func (e *textObjectEncoder) AppendDuration(v time.Duration) { e.elem(); appendDuration(e.buf, v) }
+func (e *textObjectEncoder) AppendObject(v ObjectMarshaler) error {
+	e.elem()
+	return e.appendObject(v)
+}
+func (e *textObjectEncoder) AppendArray(v ArrayMarshaler) error {
+	e.elem()
+	return e.appendArray(v)
+}
+func (e *textObjectEncoder) AppendAny(v interface{}) error {
+	e.elem()
+	return e.appendAny(v)
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testAddr struct {
+	Host string
+	Port int
+}
+
+func (a testAddr) MarshalLogObject(enc ObjectEncoder) error {
+	enc.AddString("host", a.Host)
+	enc.AddInt("port", a.Port)
+	return nil
+}
+
+type testAddrs []testAddr
+
+func (as testAddrs) MarshalLogArray(enc ArrayEncoder) error {
+	for _, a := range as {
+		if err := enc.AppendObject(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type testRequest struct {
+	Method  string
+	Timeout time.Duration
+	Addr    testAddr
+	Backups testAddrs
+	Tags    []string
+}
+
+func (r testRequest) MarshalLogObject(enc ObjectEncoder) error {
+	enc.AddString("method", r.Method)
+	enc.AddDuration("timeout", r.Timeout)
+	enc.AddObject("addr", r.Addr)
+	enc.AddArray("backups", r.Backups)
+	enc.AddObject("empty", ObjectMarshalerFunc(func(ObjectEncoder) error { return nil }))
+	return enc.AddAny("tags", r.Tags)
+}
+
+func TestObjectMarshaler(t *testing.T) {
+	req := testRequest{
+		Method:  "GET",
+		Timeout: time.Second,
+		Addr:    testAddr{Host: "127.0.0.1", Port: 80},
+		Backups: testAddrs{{Host: "127.0.0.2", Port: 81}, {Host: "127.0.0.3", Port: 82}},
+		Tags:    []string{"a", "b"},
+	}
+	failed := ObjectMarshalerFunc(func(enc ObjectEncoder) error {
+		enc.AddBool("ok", false)
+		return errors.New("error")
+	})
+
+	buf := NewBuilder(512)
+	logger := New("").WithEncoder(TextEncoder(StreamWriter(buf)))
+	logger.Info("msg", Object("req", req), F("addrs", req.Backups), F("failed", failed))
+	expect := "req.method=GET req.timeout=1s req.addr.host=127.0.0.1 req.addr.port=80 " +
+		"req.backups=[{host=127.0.0.2 port=81} {host=127.0.0.3 port=82}] req.empty={} " +
+		"req.tags=[a b] addrs=[{host=127.0.0.2 port=81} {host=127.0.0.3 port=82}] " +
+		"failed.ok=false failedError=error msg=msg\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Encoder = JSONEncoder(StreamWriter(buf))
+	logger.Info("msg", F("req", req), Array("addrs", req.Backups), Object("failed", failed))
+	expect = `{"req":{"method":"GET","timeout":"1s","addr":{"host":"127.0.0.1","port":80},` +
+		`"backups":[{"host":"127.0.0.2","port":81},{"host":"127.0.0.3","port":82}],` +
+		`"empty":{},"tags":["a","b"]},"addrs":[{"host":"127.0.0.2","port":81},` +
+		`{"host":"127.0.0.3","port":82}],"failed":{"ok":false},"failedError":"error","msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	var ms map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &ms); err != nil {
+		t.Error(err)
+	}
+
+	fields := []Field{Object("addr", req.Addr)}
+	if n := testing.AllocsPerRun(100, func() { logger.Info("msg", fields...) }); n != 0 && !raceEnabled {
+		t.Errorf("expect no allocation, but got %v", n)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	buf := NewBuilder(256)
+	logger := New("").WithEncoder(JSONEncoder(StreamWriter(buf)))
+	logger = logger.WithCtx(F("ctx", 1)).WithGroup("http").WithCtx(F("method", "GET"))
+	logger.Info("msg", Int("code", 200), Namespace("req"), Object("addr", testAddr{Host: "localhost", Port: 80}))
+	logger.WithGroup("empty").Info("msg")
+	logger.Info("msg", Namespace("err"), E(FE(errors.New("error"), FB(1).F("retry", 1))))
+
+	expect := `{"ctx":1,"http":{"method":"GET","code":200,"req":{"addr":{"host":"localhost","port":80}}},"msg":"msg"}` + "\n" +
+		`{"ctx":1,"http":{"method":"GET","empty":{}},"msg":"msg"}` + "\n" +
+		`{"ctx":1,"http":{"method":"GET","err":{"err":"error","retry":1}},"msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Encoder = TextEncoder(StreamWriter(buf))
+	logger.Info("msg", Int("code", 200), Namespace("req"), Object("addr", testAddr{Host: "localhost", Port: 80}))
+	logger.WithGroup("empty").Info("msg")
+	logger.Info("msg", Namespace("err"), E(FE(errors.New("error"), FB(1).F("retry", 1))))
+
+	expect = "ctx=1 http.method=GET http.code=200 http.req.addr.host=localhost http.req.addr.port=80 msg=msg\n" +
+		"ctx=1 http.method=GET msg=msg\n" +
+		"ctx=1 http.method=GET http.err.err=error http.err.retry=1 msg=msg\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
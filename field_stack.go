@@ -43,6 +43,10 @@ func (f stackField) Stack(depth int) interface{} { return f.stack(depth + 1) }
 // Caller returns a StackField that returns the caller "file:line".
 //
 // If fullPath is true, the file is the full path but removing the GOPATH prefix.
+//
+// Notice: the caller is resolved by the stack depth, so the encoder option
+// EncodeCaller with ExtLogger.CallerPC is recommended instead, which resolves
+// the caller from the program counter captured once by the logger.
 func Caller(key string, fullPath ...bool) StackField {
 	format := "%v"
 	if len(fullPath) > 0 && fullPath[0] {
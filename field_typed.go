@@ -0,0 +1,509 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"unicode"
+)
+
+type fieldType uint8
+
+const (
+	intType fieldType = iota + 1
+	uintType
+	floatType
+	boolType
+	stringType
+	durationType
+	timeType     // The time is stored as the unix nanoseconds and the location.
+	timeFullType // The time is out of the range of the unix nanoseconds.
+	bytesType
+	errorType
+	stringerType
+	objectType
+	arrayType
+	namespaceType
+)
+
+// typedField is a field with the type tag and the union storage,
+// which avoids to box the value into interface{} and lets the encoders
+// encode the value without the type switch.
+//
+// Notice: the field itself is still allocated when converted to Field,
+// which may be avoided by TypedFieldBuilder.
+type typedField struct {
+	key   string
+	typ   fieldType
+	num   uint64
+	str   string
+	bytes []byte
+	any   interface{}
+}
+
+// asTypedField returns the typed field stored in the field by the value
+// or by the pointer from TypedFieldBuilder.
+func asTypedField(field Field) (typedField, bool) {
+	switch f := field.(type) {
+	case typedField:
+		return f, true
+	case *typedField:
+		return *f, true
+	default:
+		return typedField{}, false
+	}
+}
+
+// Int returns a new int Field.
+func Int(key string, value int) Field { return int64Field(key, int64(value)) }
+
+// Int64 returns a new int64 Field.
+func Int64(key string, value int64) Field { return int64Field(key, value) }
+
+// Uint64 returns a new uint64 Field.
+func Uint64(key string, value uint64) Field { return uint64Field(key, value) }
+
+// Float64 returns a new float64 Field.
+func Float64(key string, value float64) Field { return float64Field(key, value) }
+
+// Bool returns a new bool Field.
+func Bool(key string, value bool) Field { return boolField(key, value) }
+
+// Str returns a new string Field.
+func Str(key string, value string) Field { return stringField(key, value) }
+
+// Dur returns a new time.Duration Field, which is encoded like
+// time.Duration.String, such as "1.5s".
+func Dur(key string, value time.Duration) Field { return durationField(key, value) }
+
+// Time returns a new time.Time Field, which is encoded by the time format
+// of the encoder, like the field F(key, value).
+func Time(key string, value time.Time) Field { return timeField(key, value) }
+
+// Bytes returns a new []byte Field, which is encoded as the string.
+//
+// Notice: the field references the value, so DON'T MODIFY it
+// before the log is emitted.
+func Bytes(key string, value []byte) Field { return bytesField(key, value) }
+
+// Err returns a new error Field.
+//
+// If err is FieldError, it is equal to F(key, err).
+func Err(key string, err error) Field {
+	if _, ok := err.(FieldError); ok {
+		return F(key, err)
+	}
+	return errorField(key, err)
+}
+
+// Stringer returns a new fmt.Stringer Field, which is encoded
+// as value.String().
+func Stringer(key string, value fmt.Stringer) Field { return stringerField(key, value) }
+
+func int64Field(key string, value int64) typedField {
+	return typedField{key: key, typ: intType, num: uint64(value)}
+}
+
+func uint64Field(key string, value uint64) typedField {
+	return typedField{key: key, typ: uintType, num: value}
+}
+
+func float64Field(key string, value float64) typedField {
+	return typedField{key: key, typ: floatType, num: math.Float64bits(value)}
+}
+
+func boolField(key string, value bool) typedField {
+	var num uint64
+	if value {
+		num = 1
+	}
+	return typedField{key: key, typ: boolType, num: num}
+}
+
+func stringField(key string, value string) typedField {
+	return typedField{key: key, typ: stringType, str: value}
+}
+
+func durationField(key string, value time.Duration) typedField {
+	return typedField{key: key, typ: durationType, num: uint64(value)}
+}
+
+func timeField(key string, value time.Time) typedField {
+	// time.Time.UnixNano is undefined out of the range about [1678, 2262].
+	if year := value.Year(); year < 1678 || year > 2261 {
+		return typedField{key: key, typ: timeFullType, any: value}
+	}
+	return typedField{key: key, typ: timeType, num: uint64(value.UnixNano()),
+		any: value.Location()}
+}
+
+func bytesField(key string, value []byte) typedField {
+	return typedField{key: key, typ: bytesType, bytes: value}
+}
+
+func errorField(key string, err error) typedField {
+	return typedField{key: key, typ: errorType, any: err}
+}
+
+func stringerField(key string, value fmt.Stringer) typedField {
+	return typedField{key: key, typ: stringerType, any: value}
+}
+
+var typedFieldBuilderPool = sync.Pool{New: func() interface{} {
+	return &TypedFieldBuilder{values: make([]typedField, 0, 8), fields: make([]Field, 0, 8)}
+}}
+
+// TypedFieldBuilder is used to build a set of the typed fields in the pooled
+// memory, which neither boxes the values nor allocates the fields.
+//
+// For example,
+//
+//	fb := TFB(2).Int("code", 200).Dur("cost", cost)
+//	logger.Info("msg", fb.Fields()...)
+//	fb.Release()
+//
+// Notice: the fields reference the memory of the builder, so DON'T USE them
+// after releasing the builder, for example, adding them into the contexts
+// of the logger by WithCtx.
+type TypedFieldBuilder struct {
+	values []typedField
+	fields []Field
+}
+
+// NewTypedFieldBuilder returns a new TypedFieldBuilder from the pool
+// with the capacity of the fields.
+func NewTypedFieldBuilder(n int) *TypedFieldBuilder {
+	b := typedFieldBuilderPool.Get().(*TypedFieldBuilder)
+	if n > cap(b.values) {
+		b.values = make([]typedField, 0, n)
+		b.fields = make([]Field, 0, n)
+	}
+	return b
+}
+
+// TFB is the alias of NewTypedFieldBuilder.
+func TFB(cap int) *TypedFieldBuilder { return NewTypedFieldBuilder(cap) }
+
+func (b *TypedFieldBuilder) add(f typedField) *TypedFieldBuilder {
+	// The pointers to the old memory are still valid if growing,
+	// because the values are never modified until released.
+	b.values = append(b.values, f)
+	b.fields = append(b.fields, &b.values[len(b.values)-1])
+	return b
+}
+
+// Int appends the int field, which is equal to Int(key, value).
+func (b *TypedFieldBuilder) Int(key string, value int) *TypedFieldBuilder {
+	return b.add(int64Field(key, int64(value)))
+}
+
+// Int64 appends the int64 field, which is equal to Int64(key, value).
+func (b *TypedFieldBuilder) Int64(key string, value int64) *TypedFieldBuilder {
+	return b.add(int64Field(key, value))
+}
+
+// Uint64 appends the uint64 field, which is equal to Uint64(key, value).
+func (b *TypedFieldBuilder) Uint64(key string, value uint64) *TypedFieldBuilder {
+	return b.add(uint64Field(key, value))
+}
+
+// Float64 appends the float64 field, which is equal to Float64(key, value).
+func (b *TypedFieldBuilder) Float64(key string, value float64) *TypedFieldBuilder {
+	return b.add(float64Field(key, value))
+}
+
+// Bool appends the bool field, which is equal to Bool(key, value).
+func (b *TypedFieldBuilder) Bool(key string, value bool) *TypedFieldBuilder {
+	return b.add(boolField(key, value))
+}
+
+// Str appends the string field, which is equal to Str(key, value).
+func (b *TypedFieldBuilder) Str(key string, value string) *TypedFieldBuilder {
+	return b.add(stringField(key, value))
+}
+
+// Dur appends the time.Duration field, which is equal to Dur(key, value).
+func (b *TypedFieldBuilder) Dur(key string, value time.Duration) *TypedFieldBuilder {
+	return b.add(durationField(key, value))
+}
+
+// Time appends the time.Time field, which is equal to Time(key, value).
+func (b *TypedFieldBuilder) Time(key string, value time.Time) *TypedFieldBuilder {
+	return b.add(timeField(key, value))
+}
+
+// Bytes appends the []byte field, which is equal to Bytes(key, value).
+func (b *TypedFieldBuilder) Bytes(key string, value []byte) *TypedFieldBuilder {
+	return b.add(bytesField(key, value))
+}
+
+// Err appends the error field, which is equal to Err(key, err).
+func (b *TypedFieldBuilder) Err(key string, err error) *TypedFieldBuilder {
+	if _, ok := err.(FieldError); ok {
+		b.fields = append(b.fields, F(key, err))
+		return b
+	}
+	return b.add(errorField(key, err))
+}
+
+// Stringer appends the fmt.Stringer field, which is equal to Stringer(key, value).
+func (b *TypedFieldBuilder) Stringer(key string, value fmt.Stringer) *TypedFieldBuilder {
+	return b.add(stringerField(key, value))
+}
+
+// Fields returns the built fields.
+func (b *TypedFieldBuilder) Fields() []Field { return b.fields }
+
+// Release releases the builder into the pool.
+func (b *TypedFieldBuilder) Release() {
+	for i := range b.values {
+		b.values[i] = typedField{} // Release the references to the values.
+	}
+	for i := range b.fields {
+		b.fields[i] = nil
+	}
+	b.values = b.values[:0]
+	b.fields = b.fields[:0]
+	typedFieldBuilderPool.Put(b)
+}
+
+func (f typedField) Key() string { return f.key }
+func (f typedField) Value() interface{} {
+	switch f.typ {
+	case intType:
+		return int64(f.num)
+	case uintType:
+		return f.num
+	case floatType:
+		return math.Float64frombits(f.num)
+	case boolType:
+		return f.num == 1
+	case stringType:
+		return f.str
+	case durationType:
+		return time.Duration(f.num)
+	case timeType:
+		return f.time()
+	case bytesType:
+		return f.bytes
+	case namespaceType:
+		return nil
+	default: // timeFullType, errorType, stringerType, objectType, arrayType
+		return f.any
+	}
+}
+
+func (f typedField) time() time.Time {
+	if f.typ == timeFullType {
+		return f.any.(time.Time)
+	}
+	return time.Unix(0, int64(f.num)).In(f.any.(*time.Location))
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+func textEncodeTypedField(buf *Builder, f typedField, quote bool, timeFmt string) {
+	switch f.typ {
+	case intType:
+		buf.AppendInt(int64(f.num))
+	case uintType:
+		buf.AppendUint(f.num)
+	case floatType:
+		buf.AppendFloat(math.Float64frombits(f.num), 64)
+	case boolType:
+		buf.AppendBool(f.num == 1)
+	case stringType:
+		appendString(buf, f.str, quote)
+	case durationType:
+		appendDuration(buf, time.Duration(f.num))
+	case timeType, timeFullType:
+		encodeTime(buf, f.time(), timeFmt)
+	case bytesType:
+		if b := f.bytes; quote && bytes.IndexFunc(b, unicode.IsSpace) > -1 {
+			buf.AppendJSONBytes(b)
+		} else {
+			buf.Write(b)
+		}
+	case errorType:
+		if f.any == nil {
+			buf.AppendAny(nil)
+		} else {
+			appendString(buf, f.any.(error).Error(), quote)
+		}
+	case stringerType:
+		if f.any == nil {
+			buf.AppendAny(nil)
+		} else {
+			appendString(buf, f.any.(fmt.Stringer).String(), quote)
+		}
+	case arrayType:
+		textEncodeValue(buf, f.key, f.any, quote, timeFmt)
+	}
+}
+
+func jsonEncodeTypedField(buf *Builder, f typedField, timeFmt string) {
+	switch f.typ {
+	case intType:
+		buf.AppendInt(int64(f.num))
+	case uintType:
+		buf.AppendUint(f.num)
+	case floatType:
+		buf.AppendFloat(math.Float64frombits(f.num), 64)
+	case boolType:
+		buf.AppendBool(f.num == 1)
+	case stringType:
+		buf.AppendJSONString(f.str)
+	case durationType:
+		buf.WriteByte('"')
+		appendDuration(buf, time.Duration(f.num))
+		buf.WriteByte('"')
+	case timeType, timeFullType:
+		buf.WriteByte('"')
+		encodeTime(buf, f.time(), timeFmt)
+		buf.WriteByte('"')
+	case bytesType:
+		buf.AppendJSONBytes(f.bytes)
+	case errorType:
+		if f.any == nil {
+			buf.WriteString("null")
+		} else {
+			buf.AppendJSONString(f.any.(error).Error())
+		}
+	case stringerType:
+		if f.any == nil {
+			buf.WriteString("null")
+		} else {
+			buf.AppendJSONString(f.any.(fmt.Stringer).String())
+		}
+	case objectType, arrayType:
+		jsonEncodeValue(buf, f.key, f.any, timeFmt)
+	}
+}
+
+// appendDuration appends the duration formatted like time.Duration.String,
+// but without the allocation.
+func appendDuration(buf *Builder, d time.Duration) {
+	// Largest time is 2540400h10m10.000000000s
+	var b [32]byte
+	w := len(b)
+
+	u := uint64(d)
+	neg := d < 0
+	if neg {
+		u = -u
+	}
+
+	if u < uint64(time.Second) {
+		// Special case: if duration is smaller than a second,
+		// use smaller units, like 1.2ms
+		var prec int
+		w--
+		b[w] = 's'
+		w--
+		switch {
+		case u == 0:
+			buf.WriteString("0s")
+			return
+		case u < uint64(time.Microsecond):
+			prec = 0
+			b[w] = 'n'
+		case u < uint64(time.Millisecond):
+			prec = 3
+			// U+00B5 'µ' micro sign == 0xC2 0xB5
+			w--
+			copy(b[w:], "µ")
+		default:
+			prec = 6
+			b[w] = 'm'
+		}
+		w, u = fmtFrac(b[:w], u, prec)
+		w = fmtInt(b[:w], u)
+	} else {
+		w--
+		b[w] = 's'
+		w, u = fmtFrac(b[:w], u, 9)
+
+		// u is now integer seconds
+		w = fmtInt(b[:w], u%60)
+		u /= 60
+
+		// u is now integer minutes
+		if u > 0 {
+			w--
+			b[w] = 'm'
+			w = fmtInt(b[:w], u%60)
+			u /= 60
+
+			// u is now integer hours
+			if u > 0 {
+				w--
+				b[w] = 'h'
+				w = fmtInt(b[:w], u)
+			}
+		}
+	}
+
+	if neg {
+		w--
+		b[w] = '-'
+	}
+
+	buf.Write(b[w:])
+}
+
+// fmtFrac formats the fraction of v/10**prec (e.g., ".12345") into the
+// tail of buf, omitting trailing zeros. It omits the decimal point too
+// when the fraction is 0. It returns the index where the output bytes begin
+// and the value v/10**prec.
+func fmtFrac(buf []byte, v uint64, prec int) (nw int, nv uint64) {
+	// Omit trailing zeros up to and including decimal point.
+	w := len(buf)
+	print := false
+	for i := 0; i < prec; i++ {
+		digit := v % 10
+		print = print || digit != 0
+		if print {
+			w--
+			buf[w] = byte(digit) + '0'
+		}
+		v /= 10
+	}
+	if print {
+		w--
+		buf[w] = '.'
+	}
+	return w, v
+}
+
+// fmtInt formats v into the tail of buf.
+// It returns the index where the output begins.
+func fmtInt(buf []byte, v uint64) int {
+	w := len(buf)
+	if v == 0 {
+		w--
+		buf[w] = '0'
+	} else {
+		for v > 0 {
+			w--
+			buf[w] = byte(v%10) + '0'
+			v /= 10
+		}
+	}
+	return w
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+type testStringer string
+
+func (s testStringer) String() string { return string(s) }
+
+var (
+	typedTime  = time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	typedBytes = []byte("bytes")
+	typedErr   = errors.New("error")
+)
+
+func typedFields() []Field {
+	return []Field{
+		Int("int", -1),
+		Int64("int64", -64),
+		Uint64("uint64", 64),
+		Float64("float64", 1.5),
+		Bool("bool", true),
+		Str("str", "a b"),
+		Dur("dur", time.Millisecond*1500),
+		Time("time", typedTime),
+		Bytes("bytes", typedBytes),
+		Err("err", typedErr),
+		Err("nil", nil),
+		Stringer("stringer", testStringer("stringer")),
+	}
+}
+
+func typedFieldBuilder() *TypedFieldBuilder {
+	return TFB(12).
+		Int("int", -1).
+		Int64("int64", -64).
+		Uint64("uint64", 64).
+		Float64("float64", 1.5).
+		Bool("bool", true).
+		Str("str", "a b").
+		Dur("dur", time.Millisecond*1500).
+		Time("time", typedTime).
+		Bytes("bytes", typedBytes).
+		Err("err", typedErr).
+		Err("nil", nil).
+		Stringer("stringer", testStringer("stringer"))
+}
+
+func TestTypedFields(t *testing.T) {
+	buf := NewBuilder(256)
+	logger := New("").WithEncoder(TextEncoder(StreamWriter(buf), Quote()))
+	logger.Info("msg", typedFields()...)
+	expect := `int=-1 int64=-64 uint64=64 float64=1.5 bool=true str="a b" dur=1.5s ` +
+		`time=1601553600 bytes=bytes err=error nil=<nil> stringer=stringer msg=msg` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Encoder = JSONEncoder(StreamWriter(buf))
+	logger.Info("msg", typedFields()...)
+	expect = `{"int":-1,"int64":-64,"uint64":64,"float64":1.5,"bool":true,"str":"a b",` +
+		`"dur":"1.5s","time":"1601553600","bytes":"bytes","err":"error","nil":null,` +
+		`"stringer":"stringer","msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	var ms map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &ms); err != nil {
+		t.Error(err)
+	}
+
+	// The fields built by TypedFieldBuilder are the same.
+	buf.Reset()
+	fb := typedFieldBuilder()
+	logger.Info("msg", fb.Fields()...)
+	fb.Release()
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	// The values must be the same as those encoded by F.
+	buf.Reset()
+	logger.Encoder = TextEncoder(StreamWriter(buf), Quote())
+	fields := typedFields()
+	for i, field := range fields {
+		fields[i] = F(field.Key(), field.Value())
+	}
+	logger.Info("msg", fields...)
+	if expect := `int=-1 int64=-64 uint64=64 float64=1.5 bool=true str="a b" dur=1.5s ` +
+		`time=1601553600 bytes=bytes err=error nil=<nil> stringer=stringer msg=msg` + "\n"; buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
+
+func TestAppendDuration(t *testing.T) {
+	buf := NewBuilder(32)
+	for _, d := range []time.Duration{0, 1, 1100, 2200 * time.Microsecond,
+		-3300 * time.Millisecond, 4*time.Minute + 5*time.Second,
+		4*time.Minute + 5001*time.Millisecond, 5*time.Hour + 6*time.Minute + 7001*time.Millisecond,
+		1<<63 - 1, -1 << 63} {
+		buf.Reset()
+		appendDuration(buf, d)
+		if buf.String() != d.String() {
+			t.Errorf("expect '%s', but got '%s'", d.String(), buf.String())
+		}
+	}
+}
+
+func TestTypedFieldsAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops the items randomly with the race detector")
+	}
+
+	fields := typedFields()
+	for _, enc := range []Encoder{TextEncoder(DiscardWriter()), JSONEncoder(DiscardWriter())} {
+		logger := New("").WithEncoder(enc).WithCtx(fields...)
+		if n := testing.AllocsPerRun(100, func() { logger.Info("msg", fields...) }); n != 0 {
+			t.Errorf("expect no allocation to encode the fields, but got %v", n)
+		}
+	}
+
+	// Only the fields and the variadic slice are allocated,
+	// but the values are not boxed.
+	logger := New("").WithEncoder(TextEncoder(DiscardWriter()))
+	typed := testing.AllocsPerRun(100, func() {
+		logger.Info("msg", Int("k1", 1000), Str("k2", "v"), Dur("k3", time.Second), Float64("k4", 1.5))
+	})
+	if typed != 5 {
+		t.Errorf("expect 5 allocations, but got %v", typed)
+	}
+
+	boxed := testing.AllocsPerRun(100, func() {
+		logger.Info("msg", F("k1", 1000), F("k2", "v"), F("k3", time.Second), F("k4", 1.5))
+	})
+	if typed >= boxed {
+		t.Errorf("expect fewer allocations than F(%v), but got %v", boxed, typed)
+	}
+
+	// TypedFieldBuilder allocates nothing.
+	for _, enc := range []Encoder{TextEncoder(DiscardWriter()), JSONEncoder(DiscardWriter()),
+		LogfmtEncoder(DiscardWriter()), ConsoleEncoder(DiscardWriter())} {
+		logger := New("").WithEncoder(enc)
+		n := testing.AllocsPerRun(100, func() {
+			fb := typedFieldBuilder()
+			logger.Info("msg", fb.Fields()...)
+			fb.Release()
+		})
+		if n != 0 {
+			t.Errorf("%T: expect no allocation, but got %v", enc, n)
+		}
+	}
+}
@@ -36,12 +36,29 @@ var DefalutLogger = New("")
 // WithCtx is equal to DefalutLogger.WithCtx(fields...).
 func WithCtx(fields ...Field) *ExtLogger { return DefalutLogger.WithCtx(fields...) }
 
+// WithGroup is equal to DefalutLogger.WithGroup(name).
+func WithGroup(name string) *ExtLogger { return DefalutLogger.WithGroup(name) }
+
 // WithName is equal to DefalutLogger.WithName(name).
 func WithName(name string) *ExtLogger { return DefalutLogger.WithName(name) }
 
 // WithLevel is equal to DefalutLogger.WithLevel(level).
 func WithLevel(level Level) *ExtLogger { return DefalutLogger.WithLevel(level) }
 
+// WithAtomicLevel is equal to DefalutLogger.WithAtomicLevel(level).
+func WithAtomicLevel(level *AtomicLevel) *ExtLogger {
+	return DefalutLogger.WithAtomicLevel(level)
+}
+
+// WithHooks is equal to DefalutLogger.WithHooks(hooks).
+func WithHooks(hooks *Hooks) *ExtLogger { return DefalutLogger.WithHooks(hooks) }
+
+// WithClock is equal to DefalutLogger.WithClock(clock).
+func WithClock(clock Clock) *ExtLogger { return DefalutLogger.WithClock(clock) }
+
+// WithCallerPC is equal to DefalutLogger.WithCallerPC(enabled).
+func WithCallerPC(enabled bool) *ExtLogger { return DefalutLogger.WithCallerPC(enabled) }
+
 // WithEncoder is equal to DefalutLogger.WithEncoder(enc).
 func WithEncoder(enc Encoder) *ExtLogger { return DefalutLogger.WithEncoder(enc) }
 
@@ -63,9 +80,20 @@ func Warn(msg string, fields ...Field) { DefalutLogger.Log(LvlWarn, 1, msg, nil,
 // Error is equal to DefalutLogger.Error(msg, fields...).
 func Error(msg string, fields ...Field) { DefalutLogger.Log(LvlError, 1, msg, nil, fields) }
 
+// Panic is equal to DefalutLogger.Panic(msg, fields...).
+func Panic(msg string, fields ...Field) { DefalutLogger.Log(LvlPanic, 1, msg, nil, fields) }
+
 // Fatal is equal to DefalutLogger.Fatal(msg, fields...).
 func Fatal(msg string, fields ...Field) { DefalutLogger.Log(LvlFatal, 1, msg, nil, fields) }
 
+// RecoverAndLog is equal to DefalutLogger.RecoverAndLog(repanic, fields...),
+// which must be called directly by defer.
+func RecoverAndLog(repanic bool, fields ...Field) {
+	if v := recover(); v != nil {
+		DefalutLogger.logPanic(v, repanic, fields)
+	}
+}
+
 // Tracef is equal to DefalutLogger.Tracef(msg, args...).
 func Tracef(msg string, args ...interface{}) { DefalutLogger.Log(LvlTrace, 1, msg, args, nil) }
 
@@ -81,6 +109,9 @@ func Warnf(msg string, args ...interface{}) { DefalutLogger.Log(LvlWarn, 1, msg,
 // Errorf is equal to DefalutLogger.Errorf(msg, args...).
 func Errorf(msg string, args ...interface{}) { DefalutLogger.Log(LvlError, 1, msg, args, nil) }
 
+// Panicf is equal to DefalutLogger.Panicf(msg, args...).
+func Panicf(msg string, args ...interface{}) { DefalutLogger.Log(LvlPanic, 1, msg, args, nil) }
+
 // Fatalf is equal to DefalutLogger.Fatalf(msg, args...).
 func Fatalf(msg string, args ...interface{}) { DefalutLogger.Log(LvlFatal, 1, msg, args, nil) }
 
@@ -0,0 +1,171 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// Hook is called with the log record before encoding it, which may observe
+// the record, enrich it by modifying it, or veto it by returning false.
+//
+// The depth of the record is relative to the caller of the hook, so the hook
+// can use r.Depth+1 to get the caller stack of the log, like Encoder.
+type Hook func(r *Record) bool
+
+type levelHook struct {
+	name string
+	mask uint64
+	hook Hook
+}
+
+func (h levelHook) enabled(lvl Level) bool {
+	return h.mask == 0 || (lvl < 64 && h.mask&(1<<lvl) != 0)
+}
+
+// Hooks is a set of the hooks, which are called in turn by the registration
+// order when emitting the log. If a hook vetoes the record, the rest hooks
+// are not called and the record is not encoded.
+//
+// If a hook panics, the panic will be recovered and reported by OnPanic,
+// and the record continues to be passed to the rest hooks as if it returned
+// true, so a faulty hook does not break logging.
+//
+// Hooks is thread-safe, and it is lock-free to run the hooks.
+type Hooks struct {
+	// OnPanic is called with the hook name and the recovered value
+	// when the hook panics.
+	//
+	// Default: write the panic into os.Stderr.
+	OnPanic func(name string, r *Record, v interface{})
+
+	lock  sync.Mutex
+	hooks atomic.Value // []levelHook
+}
+
+// NewHooks returns a new Hooks.
+func NewHooks() *Hooks { return new(Hooks) }
+
+// Add appends the hook named name, which is only called for the logs
+// whose level is one of levels. If levels is empty, it is for all the levels.
+//
+// If the hook named name has been added, it will be replaced in place,
+// that's, its order is not changed.
+func (hs *Hooks) Add(name string, hook Hook, levels ...Level) {
+	if hook == nil {
+		panic("Hooks: the hook must not be nil")
+	}
+
+	h := levelHook{name: name, hook: hook}
+	for _, lvl := range levels {
+		if lvl < 64 {
+			h.mask |= 1 << lvl
+		}
+	}
+
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	olds := hs.load()
+	news := make([]levelHook, len(olds), len(olds)+1)
+	copy(news, olds)
+	for i := range news {
+		if news[i].name == name {
+			news[i] = h
+			hs.hooks.Store(news)
+			return
+		}
+	}
+	hs.hooks.Store(append(news, h))
+}
+
+// Remove removes the hook named name.
+func (hs *Hooks) Remove(name string) {
+	hs.lock.Lock()
+	defer hs.lock.Unlock()
+
+	olds := hs.load()
+	news := make([]levelHook, 0, len(olds))
+	for _, h := range olds {
+		if h.name != name {
+			news = append(news, h)
+		}
+	}
+	hs.hooks.Store(news)
+}
+
+// Names returns the names of all the hooks by the order.
+func (hs *Hooks) Names() []string {
+	hooks := hs.load()
+	names := make([]string, len(hooks))
+	for i, h := range hooks {
+		names[i] = h.name
+	}
+	return names
+}
+
+func (hs *Hooks) load() []levelHook {
+	hooks, _ := hs.hooks.Load().([]levelHook)
+	return hooks
+}
+
+// Run calls the hooks in turn with the record, and returns the record
+// which may be modified by the hooks and false if a hook vetoes it.
+//
+// The depth of the record is relative to the caller of Run, like Encoder.
+// If hs is nil, it does nothing and returns true.
+func (hs *Hooks) Run(r Record) (Record, bool) {
+	if hs == nil {
+		return r, true
+	}
+
+	hooks := hs.load()
+	if len(hooks) == 0 {
+		return r, true
+	}
+
+	return hs.run(hooks, r)
+}
+
+func (hs *Hooks) run(hooks []levelHook, r Record) (Record, bool) {
+	// Run -> run -> call -> hook
+	r.Depth += 3
+	ok := true
+	for _, h := range hooks {
+		if h.enabled(r.Lvl) && !hs.call(h, &r) {
+			ok = false
+			break
+		}
+	}
+	r.Depth -= 3
+	return r, ok
+}
+
+func (hs *Hooks) call(h levelHook, r *Record) (ok bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			ok = true
+			if hs.OnPanic == nil {
+				fmt.Fprintf(os.Stderr, "klog: hook '%s' panics: %v\n", h.name, v)
+			} else {
+				hs.OnPanic(h.name, r, v)
+			}
+		}
+	}()
+	return h.hook(r)
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/go-stack/stack"
+)
+
+func TestHooks(t *testing.T) {
+	var errors, panics int
+	var callers []string
+	hooks := NewHooks()
+	hooks.OnPanic = func(name string, r *Record, v interface{}) {
+		if name != "panic" || v != "test" {
+			t.Errorf("unexpected panic of the hook '%s': %v", name, v)
+		}
+		panics++
+	}
+
+	hooks.Add("caller", func(r *Record) bool {
+		callers = append(callers, fmt.Sprint(stack.Caller(r.Depth+1)))
+		return true
+	})
+	hooks.Add("panic", func(r *Record) bool { panic("test") }, LvlWarn)
+	hooks.Add("veto", func(r *Record) bool { return r.Msg != "veto" })
+	hooks.Add("enrich", func(r *Record) bool {
+		r.Fields = append(r.Fields, F("hook", true))
+		return true
+	})
+	hooks.Add("errors", func(r *Record) bool { errors++; return true }, LvlError)
+	if names := hooks.Names(); !reflect.DeepEqual(names,
+		[]string{"caller", "panic", "veto", "enrich", "errors"}) {
+		t.Error(names)
+	}
+
+	buf := NewBuilder(128)
+	logger := New("").WithHooks(hooks)
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeLevel("lvl"))
+
+	logger.Info("msg1")
+	logger.Info("veto")
+	logger.Warn("msg2")
+	logger.Error("msg3", Caller("caller"))
+
+	expect := "lvl=INFO hook=true msg=msg1\nlvl=WARN hook=true msg=msg2\n" +
+		"lvl=ERROR caller=hook_test.go:59 hook=true msg=msg3\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	if errors != 1 {
+		t.Errorf("expect 1 error, but got %d", errors)
+	}
+	if panics != 1 {
+		t.Errorf("expect 1 panic, but got %d", panics)
+	}
+	if expect := []string{"hook_test.go:56", "hook_test.go:57",
+		"hook_test.go:58", "hook_test.go:59"}; !reflect.DeepEqual(callers, expect) {
+		t.Errorf("expect %v, but got %v", expect, callers)
+	}
+
+	hooks.Remove("enrich")
+	buf.Reset()
+	logger.Info("msg4")
+	if expect = "lvl=INFO msg=msg4\n"; buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
@@ -17,6 +17,7 @@ package klog
 import (
 	"fmt"
 	"strings"
+	"sync/atomic"
 )
 
 // Predefine some levels.
@@ -27,6 +28,11 @@ const (
 	LvlWarn
 	LvlError
 	LvlFatal
+
+	// LvlPanic is appended after LvlFatal to keep the values of the levels
+	// above, so it is ranked above FATAL, such as being enabled when the level
+	// is FATAL and being mapped to the higher level of log/slog.
+	LvlPanic
 )
 
 // Level is the level of the log.
@@ -46,6 +52,8 @@ func (l Level) String() string {
 		return "ERROR"
 	case LvlFatal:
 		return "FATAL"
+	case LvlPanic:
+		return "PANIC"
 	default:
 		return "Unknown"
 	}
@@ -60,24 +68,58 @@ func (l Level) String() string {
 //   WARN
 //   ERROR
 //   FATAL
+//   PANIC
+//
+// If the level name is unknown and defaultLevel is not given, it will panic.
 func NameToLevel(level string, defaultLevel ...Level) Level {
+	lvl, err := ParseLevel(level)
+	if err != nil {
+		if len(defaultLevel) > 0 {
+			return defaultLevel[0]
+		}
+		panic(err)
+	}
+	return lvl
+}
+
+// ParseLevel is the same as NameToLevel, but returns an error
+// instead of panicking if the level name is unknown.
+func ParseLevel(level string) (Level, error) {
 	switch strings.ToUpper(level) {
 	case "TRACE":
-		return LvlTrace
+		return LvlTrace, nil
 	case "DEBUG":
-		return LvlDebug
+		return LvlDebug, nil
 	case "INFO":
-		return LvlInfo
+		return LvlInfo, nil
 	case "WARN":
-		return LvlWarn
+		return LvlWarn, nil
 	case "ERROR":
-		return LvlError
+		return LvlError, nil
 	case "FATAL":
-		return LvlFatal
+		return LvlFatal, nil
+	case "PANIC":
+		return LvlPanic, nil
 	default:
-		if len(defaultLevel) > 0 {
-			return defaultLevel[0]
-		}
-		panic(fmt.Errorf("unknown level '%s'", level))
+		return 0, fmt.Errorf("unknown level '%s'", level)
 	}
 }
+
+// AtomicLevel is the level which can be changed atomically at runtime,
+// and it may be shared by many loggers.
+type AtomicLevel struct {
+	level uint32
+}
+
+// NewAtomicLevel returns a new AtomicLevel with the level.
+func NewAtomicLevel(level Level) *AtomicLevel {
+	return &AtomicLevel{level: uint32(level)}
+}
+
+// Level returns the current level.
+func (l *AtomicLevel) Level() Level { return Level(atomic.LoadUint32(&l.level)) }
+
+// SetLevel resets the level to level.
+func (l *AtomicLevel) SetLevel(level Level) { atomic.StoreUint32(&l.level, uint32(level)) }
+
+func (l *AtomicLevel) String() string { return l.Level().String() }
@@ -0,0 +1,300 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LevelStore is used to get and set the levels of the loggers by the name.
+//
+// AtomicLevels and Registry have implemented the interface.
+type LevelStore interface {
+	// Levels returns the levels of all the loggers by the name.
+	Levels() map[string]Level
+
+	// GetLevel returns the level of the logger named name,
+	// and false if the logger does not exist.
+	GetLevel(name string) (Level, bool)
+
+	// SetLevel sets the level of the logger named name.
+	SetLevel(name string, level Level) error
+}
+
+// levelUnsetter is the optional interface of LevelStore, such as Registry,
+// the levels of which may be inherited and not configured by the name.
+type levelUnsetter interface {
+	ConfiguredLevel(name string) (Level, bool)
+	UnsetLevel(name string)
+}
+
+// AtomicLevels is a LevelStore based on a set of the named AtomicLevels.
+type AtomicLevels struct {
+	lock   sync.RWMutex
+	levels map[string]*AtomicLevel
+}
+
+// NewAtomicLevels returns a new AtomicLevels.
+func NewAtomicLevels() *AtomicLevels {
+	return &AtomicLevels{levels: make(map[string]*AtomicLevel)}
+}
+
+// Register registers the atomic level with the name.
+func (ls *AtomicLevels) Register(name string, level *AtomicLevel) {
+	ls.lock.Lock()
+	ls.levels[name] = level
+	ls.lock.Unlock()
+}
+
+// Unregister unregisters the atomic level by the name.
+func (ls *AtomicLevels) Unregister(name string) {
+	ls.lock.Lock()
+	delete(ls.levels, name)
+	ls.lock.Unlock()
+}
+
+// Levels implements the interface LevelStore.
+func (ls *AtomicLevels) Levels() map[string]Level {
+	ls.lock.RLock()
+	levels := make(map[string]Level, len(ls.levels))
+	for name, level := range ls.levels {
+		levels[name] = level.Level()
+	}
+	ls.lock.RUnlock()
+	return levels
+}
+
+// GetLevel implements the interface LevelStore.
+func (ls *AtomicLevels) GetLevel(name string) (level Level, ok bool) {
+	ls.lock.RLock()
+	lvl, ok := ls.levels[name]
+	ls.lock.RUnlock()
+	if ok {
+		level = lvl.Level()
+	}
+	return
+}
+
+// SetLevel implements the interface LevelStore.
+func (ls *AtomicLevels) SetLevel(name string, level Level) error {
+	ls.lock.RLock()
+	lvl, ok := ls.levels[name]
+	ls.lock.RUnlock()
+	if !ok {
+		return fmt.Errorf("no logger named '%s'", name)
+	}
+
+	lvl.SetLevel(level)
+	return nil
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// LevelHandler returns a http handler to get and set the levels
+// of the loggers in the store at runtime.
+//
+// For the GET request, it returns the levels of all the loggers as JSON,
+// such as {"levels":{"name":"INFO"}}. If the query argument "name" is given,
+// it only returns the level of the given logger, such as
+// {"name":"name","level":"INFO"}.
+//
+// For the PUT or POST request, it sets the level of the logger by the
+// arguments "name", "level" and "ttl", which may be the JSON body, the form
+// or the query, such as {"name":"name","level":"debug","ttl":"10m"}.
+// "level" is the level name supported by NameToLevel. If "ttl" is given,
+// the level will be reverted to the original after the duration. If the store
+// is Registry and the level of the logger is inherited, it will be unset
+// instead so that the logger inherits the level again.
+//
+// If failing, it returns an error as JSON, such as {"error":"reason"}.
+func LevelHandler(store LevelStore) http.Handler {
+	if store == nil {
+		panic("LevelHandler: the level store must not be nil")
+	}
+	return &levelHandler{
+		store:     store,
+		reverts:   make(map[string]*levelRevert),
+		afterFunc: afterFunc,
+	}
+}
+
+func afterFunc(d time.Duration, f func()) (stop func() bool) {
+	return time.AfterFunc(d, f).Stop
+}
+
+// levelRevert is the revert of the temporary level, which is created
+// for each timer so that the stale timer does nothing.
+type levelRevert struct {
+	stop  func() bool
+	level Level
+	unset bool // Unset the level instead since it is inherited.
+}
+
+type levelHandler struct {
+	store   LevelStore
+	lock    sync.Mutex
+	reverts map[string]*levelRevert
+
+	// afterFunc calls f after d, which may be replaced in the tests.
+	afterFunc func(d time.Duration, f func()) (stop func() bool)
+}
+
+type levelRequest struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+	TTL   string `json:"ttl,omitempty"`
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getLevel(w, r)
+	case http.MethodPut, http.MethodPost:
+		h.setLevel(w, r)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		sendLevelError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (h *levelHandler) getLevel(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		levels := h.store.Levels()
+		names := make(map[string]string, len(levels))
+		for name, level := range levels {
+			names[name] = level.String()
+		}
+		sendLevelJSON(w, http.StatusOK, map[string]interface{}{"levels": names})
+		return
+	}
+
+	level, ok := h.store.GetLevel(name)
+	if !ok {
+		sendLevelError(w, http.StatusNotFound, fmt.Sprintf("no logger named '%s'", name))
+		return
+	}
+	sendLevelJSON(w, http.StatusOK, levelRequest{Name: name, Level: level.String()})
+}
+
+func (h *levelHandler) setLevel(w http.ResponseWriter, r *http.Request) {
+	var req levelRequest
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if ct == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendLevelError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+			return
+		}
+	} else if err := r.ParseForm(); err != nil {
+		sendLevelError(w, http.StatusBadRequest, err.Error())
+		return
+	} else {
+		req.Name = r.Form.Get("name")
+		req.Level = r.Form.Get("level")
+		req.TTL = r.Form.Get("ttl")
+	}
+
+	level, err := ParseLevel(req.Level)
+	if err != nil {
+		sendLevelError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var ttl time.Duration
+	if req.TTL != "" {
+		if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+			sendLevelError(w, http.StatusBadRequest, fmt.Sprintf("invalid ttl '%s'", req.TTL))
+			return
+		}
+	}
+
+	if err = h.updateLevel(req.Name, level, ttl); err != nil {
+		sendLevelError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	req.Level = level.String()
+	sendLevelJSON(w, http.StatusOK, req)
+}
+
+func (h *levelHandler) updateLevel(name string, level Level, ttl time.Duration) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	// Revert to the original level, not the temporary one, if a revert
+	// has been scheduled.
+	var revert *levelRevert
+	pending, reverting := h.reverts[name]
+	if ttl > 0 {
+		if reverting {
+			revert = &levelRevert{level: pending.level, unset: pending.unset}
+		} else {
+			old, ok := h.store.GetLevel(name)
+			if !ok {
+				return fmt.Errorf("no logger named '%s'", name)
+			}
+
+			revert = &levelRevert{level: old}
+			if store, ok := h.store.(levelUnsetter); ok {
+				_, configured := store.ConfiguredLevel(name)
+				revert.unset = !configured
+			}
+		}
+	}
+
+	if err := h.store.SetLevel(name, level); err != nil {
+		return err
+	}
+
+	if reverting {
+		pending.stop()
+		delete(h.reverts, name)
+	}
+
+	if revert != nil {
+		revert.stop = h.afterFunc(ttl, func() { h.revertLevel(name, revert) })
+		h.reverts[name] = revert
+	}
+
+	return nil
+}
+
+func (h *levelHandler) revertLevel(name string, revert *levelRevert) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.reverts[name] == revert {
+		delete(h.reverts, name)
+		if revert.unset {
+			h.store.(levelUnsetter).UnsetLevel(name)
+		} else {
+			h.store.SetLevel(name, revert.level)
+		}
+	}
+}
+
+func sendLevelError(w http.ResponseWriter, code int, err string) {
+	sendLevelJSON(w, code, map[string]string{"error": err})
+}
+
+func sendLevelJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTimers replaces the timers of the level handler, which are fired
+// by the tests manually.
+type fakeTimers []func()
+
+func (ts *fakeTimers) afterFunc(d time.Duration, f func()) func() bool {
+	*ts = append(*ts, f)
+	return func() bool { return true }
+}
+
+type failedLevelStore struct {
+	*AtomicLevels
+	fail bool
+}
+
+func (s *failedLevelStore) SetLevel(name string, level Level) error {
+	if s.fail {
+		return errors.New("failed")
+	}
+	return s.AtomicLevels.SetLevel(name, level)
+}
+
+func TestLevelHandler(t *testing.T) {
+	level := NewAtomicLevel(LvlInfo)
+	levels := NewAtomicLevels()
+	levels.Register("db", level)
+	handler := LevelHandler(levels)
+
+	var timers fakeTimers
+	handler.(*levelHandler).afterFunc = timers.afterFunc
+
+	serve := func(method, url, ct, body string) (int, string) {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if ct != "" {
+			req.Header.Set("Content-Type", ct)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code, strings.TrimSpace(rec.Body.String())
+	}
+
+	if code, body := serve(http.MethodGet, "/", "", ""); code != 200 || body != `{"levels":{"db":"INFO"}}` {
+		t.Error(code, body)
+	}
+
+	if code, body := serve(http.MethodGet, "/?name=xxx", "", ""); code != 404 || body != `{"error":"no logger named 'xxx'"}` {
+		t.Error(code, body)
+	}
+
+	if code, body := serve(http.MethodPut, "/", "application/json", `{"name":"db","level":"xxx"}`); code != 400 || body != `{"error":"unknown level 'xxx'"}` {
+		t.Error(code, body)
+	}
+
+	if code, body := serve(http.MethodPost, "/?name=db&level=debug", "", ""); code != 200 || body != `{"name":"db","level":"DEBUG"}` {
+		t.Error(code, body)
+	} else if lvl := level.Level(); lvl != LvlDebug {
+		t.Error(lvl)
+	}
+
+	code, body := serve(http.MethodPut, "/", "application/json", `{"name":"db","level":"trace","ttl":"10ms"}`)
+	if code != 200 || body != `{"name":"db","level":"TRACE","ttl":"10ms"}` {
+		t.Error(code, body)
+	} else if lvl := level.Level(); lvl != LvlTrace {
+		t.Error(lvl)
+	}
+
+	// Reset the ttl, but the stale timer has fired.
+	serve(http.MethodPut, "/", "application/json", `{"name":"db","level":"warn","ttl":"10ms"}`)
+	if len(timers) != 2 {
+		t.Fatalf("expect 2 timers, but got %d", len(timers))
+	}
+	timers[0]()
+	if lvl := level.Level(); lvl != LvlWarn {
+		t.Errorf("expected the level '%s', but got '%s'", LvlWarn, lvl)
+	}
+
+	timers[1]()
+	if lvl := level.Level(); lvl != LvlDebug {
+		t.Errorf("expected the level '%s', but got '%s'", LvlDebug, lvl)
+	}
+}
+
+func TestLevelHandlerSetLevelFailure(t *testing.T) {
+	level := NewAtomicLevel(LvlInfo)
+	store := &failedLevelStore{AtomicLevels: NewAtomicLevels()}
+	store.Register("db", level)
+	handler := LevelHandler(store).(*levelHandler)
+
+	var timers fakeTimers
+	handler.afterFunc = timers.afterFunc
+	if err := handler.updateLevel("db", LvlDebug, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pending revert is kept if failing to set the level.
+	store.fail = true
+	if err := handler.updateLevel("db", LvlTrace, 0); err == nil {
+		t.Error("expect an error")
+	}
+	store.fail = false
+
+	timers[0]()
+	if lvl := level.Level(); lvl != LvlInfo {
+		t.Errorf("expected the level '%s', but got '%s'", LvlInfo, lvl)
+	}
+}
+
+func TestLevelHandlerRegistry(t *testing.T) {
+	registry := NewRegistry(New("").WithLevel(LvlInfo))
+	registry.SetLevel("db", LvlWarn)
+	registry.SetLevel("http", LvlError)
+	handler := LevelHandler(registry).(*levelHandler)
+
+	var timers fakeTimers
+	handler.afterFunc = timers.afterFunc
+	revert := func() { timers[len(timers)-1]() }
+
+	// The inherited level is unset after reverting.
+	if err := handler.updateLevel("db.pool", LvlTrace, time.Hour); err != nil {
+		t.Fatal(err)
+	} else if lvl := registry.EffectiveLevel("db.pool"); lvl != LvlTrace {
+		t.Errorf("expected the level '%s', but got '%s'", LvlTrace, lvl)
+	}
+	revert()
+	registry.SetLevel("db", LvlDebug)
+	if _, ok := registry.ConfiguredLevel("db.pool"); ok {
+		t.Errorf("unexpected the configured level of 'db.pool'")
+	} else if lvl := registry.EffectiveLevel("db.pool"); lvl != LvlDebug {
+		t.Errorf("expected the level '%s', but got '%s'", LvlDebug, lvl)
+	}
+
+	// The configured level is restored after reverting.
+	if err := handler.updateLevel("http", LvlTrace, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	revert()
+	if lvl, ok := registry.ConfiguredLevel("http"); !ok || lvl != LvlError {
+		t.Errorf("expected the configured level '%s', but got '%s'", LvlError, lvl)
+	}
+}
@@ -15,22 +15,64 @@
 package klog
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 )
 
 var fixDepth = func(depth int) int { return depth }
 
+// osExit is used to exit the program for the level FATAL.
+var osExit = os.Exit
+
+// Clock is used to get the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc converts a function to Clock.
+type ClockFunc func() time.Time
+
+// Now implements the interface Clock.
+func (f ClockFunc) Now() time.Time { return f() }
+
 // ExtLogger is a extended logger implemented the Logger and Loggerf interface.
+//
+// If AtomicLevel is set, it will be used as the level instead of Level,
+// and it is shared by all the loggers derived from it, so you can change
+// the level of all of them at runtime by AtomicLevel.SetLevel.
+//
+// If VModule is set, the log whose level is lower than that of the logger
+// is still emitted when the call site is enabled by VModule.
+//
+// If Hooks is set, the hooks will be run with the record before encoding it,
+// and it is shared by all the loggers derived from it.
+//
+// If CallerPC is true, the program counter of the caller is captured once
+// into Record.PC, from which the encoders resolve the caller, such as
+// the option EncodeCaller.
+//
+// If Clock is set, it is used to get the time of the record instead of
+// time.Now, which is useful for the deterministic tests.
 type ExtLogger struct {
-	Name    string
-	Ctxs    []Field
-	Depth   int
-	Level   Level
-	Encoder Encoder
+	Name        string
+	Ctxs        []Field
+	Depth       int
+	Level       Level
+	AtomicLevel *AtomicLevel
+	VModule     *VModule
+	Hooks       *Hooks
+	Clock       Clock
+	CallerPC    bool
+	Encoder     Encoder
+
+	registry  *Registry
+	ctxsCache *ctxsCache
 }
 
 // New creates a new ExtLogger, which will use TextEncoder as the encoder
@@ -62,7 +104,16 @@ func (l *ExtLogger) StdLog(prefix string, flags ...int) *log.Logger {
 	if len(flags) > 0 {
 		flag = flags[0]
 	}
-	return log.New(ToIOWriter(l.Encoder.Writer(), l.Level), prefix, flag)
+	return log.New(ToIOWriter(l.Encoder.Writer(), l.GetLevel()), prefix, flag)
+}
+
+// GetLevel returns the level of the logger, which is the level of AtomicLevel
+// if it is set, or Level.
+func (l *ExtLogger) GetLevel() Level {
+	if l.AtomicLevel != nil {
+		return l.AtomicLevel.Level()
+	}
+	return l.Level
 }
 
 // Clone clones itself and returns a new one.
@@ -72,26 +123,82 @@ func (l *ExtLogger) Clone() *ExtLogger {
 		ctxs = append([]Field{}, l.Ctxs...)
 	}
 
+	var cache *ctxsCache
+	if len(ctxs) != 0 {
+		cache = new(ctxsCache)
+	}
+
 	return &ExtLogger{
-		Ctxs:    ctxs,
-		Name:    l.Name,
-		Depth:   l.Depth,
-		Level:   l.Level,
-		Encoder: l.Encoder,
+		Ctxs:        ctxs,
+		Name:        l.Name,
+		Depth:       l.Depth,
+		Level:       l.Level,
+		AtomicLevel: l.AtomicLevel,
+		VModule:     l.VModule,
+		Hooks:       l.Hooks,
+		Clock:       l.Clock,
+		CallerPC:    l.CallerPC,
+		Encoder:     l.Encoder,
+		registry:    l.registry,
+		ctxsCache:   cache,
 	}
 }
 
 // WithName returns a new ExtLogger with the new name.
+//
+// If the logger is got from Registry, the level of the new logger will be
+// resolved from the registry by the new name.
 func (l *ExtLogger) WithName(name string) *ExtLogger {
 	ll := l.Clone()
 	ll.Name = name
+	if ll.registry != nil {
+		ll.AtomicLevel = ll.registry.atomicLevel(name)
+	}
 	return ll
 }
 
-// WithLevel returns a new ExtLogger with the new level.
+// WithLevel returns a new ExtLogger with the new level,
+// which does not share the AtomicLevel any more.
 func (l *ExtLogger) WithLevel(level Level) *ExtLogger {
 	ll := l.Clone()
 	ll.Level = level
+	ll.AtomicLevel = nil
+	return ll
+}
+
+// WithAtomicLevel returns a new ExtLogger with the new atomic level.
+func (l *ExtLogger) WithAtomicLevel(level *AtomicLevel) *ExtLogger {
+	ll := l.Clone()
+	ll.AtomicLevel = level
+	return ll
+}
+
+// WithVModule returns a new ExtLogger with the new vmodule.
+func (l *ExtLogger) WithVModule(vmodule *VModule) *ExtLogger {
+	ll := l.Clone()
+	ll.VModule = vmodule
+	return ll
+}
+
+// WithHooks returns a new ExtLogger with the new hooks.
+func (l *ExtLogger) WithHooks(hooks *Hooks) *ExtLogger {
+	ll := l.Clone()
+	ll.Hooks = hooks
+	return ll
+}
+
+// WithClock returns a new ExtLogger with the new clock.
+func (l *ExtLogger) WithClock(clock Clock) *ExtLogger {
+	ll := l.Clone()
+	ll.Clock = clock
+	return ll
+}
+
+// WithCallerPC returns a new ExtLogger, which captures the program counter
+// of the caller into Record.PC if enabled.
+func (l *ExtLogger) WithCallerPC(enabled bool) *ExtLogger {
+	ll := l.Clone()
+	ll.CallerPC = enabled
 	return ll
 }
 
@@ -113,32 +220,104 @@ func (l *ExtLogger) WithDepth(depth int) *ExtLogger {
 func (l *ExtLogger) WithCtx(ctxs ...Field) *ExtLogger {
 	ll := l.Clone()
 	ll.Ctxs = append(ll.Ctxs, ctxs...)
+	if ll.ctxsCache == nil && len(ll.Ctxs) != 0 {
+		ll.ctxsCache = new(ctxsCache)
+	}
 	return ll
 }
 
+// WithGroup returns a new ExtLogger, which nests all the subsequent fields
+// under the group name. See Namespace.
+func (l *ExtLogger) WithGroup(name string) *ExtLogger {
+	if name == "" {
+		return l.Clone()
+	}
+	return l.WithCtx(Namespace(name))
+}
+
+func (l *ExtLogger) now() time.Time {
+	if l.Clock != nil {
+		return l.Clock.Now()
+	}
+	return time.Now()
+}
+
 // Log emits the logs with the level and the depth.
 func (l *ExtLogger) Log(lvl Level, depth int, msg string, args []interface{}, fields []Field) {
-	if lvl < l.Level {
-		return
+	l.LogCtx(nil, lvl, depth+1, msg, args, fields)
+}
+
+// LogCtx is the same as Log, but also appends the fields carried by ctx
+// and extracted by ContextExtractors before fields.
+//
+// ctx may be nil, which is equal to Log.
+func (l *ExtLogger) LogCtx(ctx context.Context, lvl Level, depth int, msg string,
+	args []interface{}, fields []Field) {
+	depth = l.Depth + 1 + fixDepth(depth)
+
+	var pc uintptr
+	if lvl < l.GetLevel() {
+		if l.VModule == nil {
+			discardLog(lvl, msg, args)
+			return
+		} else if pc = callerPC(depth); !l.VModule.EnabledPC(lvl, pc) {
+			discardLog(lvl, msg, args)
+			return
+		}
+	} else if l.CallerPC {
+		pc = callerPC(depth)
 	}
 
 	if len(args) != 0 {
 		msg = fmt.Sprintf(msg, args...)
 	}
 
+	ctxs := l.Ctxs
+	if ctx != nil {
+		ctxs, fields = appendContextFields(ctx, ctxs, fields)
+	}
+
 	r := Record{
 		Name:   l.Name,
-		Depth:  l.Depth + 1 + fixDepth(depth),
+		Time:   l.now(),
+		Depth:  depth,
+		PC:     pc,
 		Lvl:    lvl,
 		Msg:    msg,
-		Ctxs:   l.Ctxs,
+		Ctxs:   ctxs,
 		Fields: fields,
+		Ctx:    ctx,
+
+		ctxsCache: l.ctxsCache,
 	}
-	l.Encoder.Encode(r)
+	if r, ok := l.Hooks.Run(r); ok {
+		l.Encoder.Encode(r)
+	}
+
+	exitLog(lvl, msg)
+}
 
-	if lvl == LvlFatal {
+// exitLog panics with the message for the level PANIC, or calls
+// the functions in CallOnExit and exits the program for the level FATAL.
+func exitLog(lvl Level, msg string) {
+	switch lvl {
+	case LvlPanic:
+		panic(msg)
+	case LvlFatal:
 		callOnExit()
-		os.Exit(1)
+		osExit(1)
+	}
+}
+
+// discardLog is called when the log is filtered out by the level,
+// which still panics for the level PANIC or exits for the level FATAL
+// without encoding the log.
+func discardLog(lvl Level, msg string, args []interface{}) {
+	if lvl == LvlPanic || lvl == LvlFatal {
+		if len(args) != 0 {
+			msg = fmt.Sprintf(msg, args...)
+		}
+		exitLog(lvl, msg)
 	}
 }
 
@@ -157,8 +336,13 @@ func (l *ExtLogger) Warn(msg string, fields ...Field) { l.Log(LvlWarn, 1, msg, n
 // Error implements the interface Logger.
 func (l *ExtLogger) Error(msg string, fields ...Field) { l.Log(LvlError, 1, msg, nil, fields) }
 
+// Panic emits the log with the level PANIC, then panics with the message
+// even if the log is filtered out.
+func (l *ExtLogger) Panic(msg string, fields ...Field) { l.Log(LvlPanic, 1, msg, nil, fields) }
+
 // Fatal implements the interface Logger, but call the exit functions
-// in CallOnExit before the program exits.
+// in CallOnExit before the program exits. It always exits
+// even if the log is filtered out.
 func (l *ExtLogger) Fatal(msg string, fields ...Field) { l.Log(LvlFatal, 1, msg, nil, fields) }
 
 // Tracef implements the interface Loggerf.
@@ -176,9 +360,67 @@ func (l *ExtLogger) Warnf(msg string, args ...interface{}) { l.Log(LvlWarn, 1, m
 // Errorf implements the interface Loggerf.
 func (l *ExtLogger) Errorf(msg string, args ...interface{}) { l.Log(LvlError, 1, msg, args, nil) }
 
+// Panicf emits the log with the level PANIC, then panics with the message
+// even if the log is filtered out.
+func (l *ExtLogger) Panicf(msg string, args ...interface{}) { l.Log(LvlPanic, 1, msg, args, nil) }
+
 // Fatalf implements the interface Loggerf, but call the exit functions
-// in CallOnExit before the program exits.
+// in CallOnExit before the program exits. It always exits
+// even if the log is filtered out.
 func (l *ExtLogger) Fatalf(msg string, args ...interface{}) { l.Log(LvlFatal, 1, msg, args, nil) }
 
 // Printf is equal to l.Infof(msg, args...).
 func (l *ExtLogger) Printf(msg string, args ...interface{}) { l.Log(LvlInfo, 1, msg, args, nil) }
+
+// RecoverAndLog recovers the panic and emits it with the level ERROR,
+// which must be called directly by defer, for example,
+//
+//	go func() {
+//	    defer logger.RecoverAndLog(false)
+//	    // ...
+//	}()
+//
+// The log contains the panic value as the field "panic" and the stack
+// of the panic as the field "stack" by CallerStack, then fields.
+//
+// If repanic is true, it panics with the panic value again after logging.
+func (l *ExtLogger) RecoverAndLog(repanic bool, fields ...Field) {
+	if v := recover(); v != nil {
+		l.logPanic(v, repanic, fields)
+	}
+}
+
+func (l *ExtLogger) logPanic(v interface{}, repanic bool, fields []Field) {
+	fs := make([]Field, 0, len(fields)+2)
+	fs = append(fs, F("panic", v), CallerStack("stack"))
+	fs = append(fs, fields...)
+	l.Log(LvlError, panicDepth(0), "panic recovered", nil, fs)
+	if repanic {
+		panic(v)
+	}
+}
+
+// panicDepth returns the depth of the function that panics, which skips
+// the runtime frames raising the panic above depth, or returns depth
+// if not found.
+//
+// depth is the stack depth like callerPC.
+func panicDepth(depth int) int {
+	var pcs [32]uintptr
+	n := runtime.Callers(depth+2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var inRuntime bool
+	for i := 0; ; i++ {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "runtime.") {
+			inRuntime = true
+		} else if inRuntime {
+			return depth + i
+		}
+
+		if !more {
+			return depth
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtLoggerClock(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.FixedZone("CST", 8*3600))
+	buf := NewBuilder(256)
+	logger := New("").WithClock(ClockFunc(func() time.Time { return now }))
+
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeTime("t", time.RFC3339Nano))
+	logger.Info("msg")
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeTime("t", time.RFC3339), TimeLocation(time.UTC))
+	logger.Info("msg")
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeTime("t", TimeFmtUnix))
+	logger.Info("msg")
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeTime("t", TimeFmtUnixMilli))
+	logger.Info("msg")
+	logger.Encoder = JSONEncoder(StreamWriter(buf), EncodeTime("t", TimeFmtUnixNano))
+	logger.Info("msg")
+
+	expect := "t=2020-01-02T03:04:05.006+08:00 msg=msg\n" +
+		"t=2020-01-01T19:04:05Z msg=msg\n" +
+		"t=1577905445 msg=msg\n" +
+		"t=1577905445006 msg=msg\n" +
+		`{"t":"1577905445006000000","msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	var r Record
+	hooks := NewHooks()
+	hooks.Add("time", func(rr *Record) bool { r = *rr; return false })
+	logger.WithHooks(hooks).Info("msg")
+	if !r.Time.Equal(now) {
+		t.Errorf("expect the record time '%s', but got '%s'", now, r.Time)
+	}
+}
+
+func TestExtLoggerPanic(t *testing.T) {
+	buf := NewBuilder(256)
+	logger := New("").WithEncoder(TextEncoder(StreamWriter(buf), EncodeLevel("lvl")))
+
+	func() {
+		defer func() {
+			if v := recover(); v != "panic: 123" {
+				t.Errorf("expect the panic 'panic: 123', but got '%v'", v)
+			}
+		}()
+		logger.Panicf("panic: %d", 123)
+	}()
+	if expect := "lvl=PANIC msg=panic: 123\n"; buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	func() {
+		defer func() {
+			if v := recover(); v != "filtered: 1" {
+				t.Errorf("expect the panic 'filtered: 1', but got '%v'", v)
+			}
+		}()
+		logger.WithLevel(LvlPanic+1).Panicf("filtered: %d", 1) // Disable all the logs.
+	}()
+	if buf.Len() != 0 {
+		t.Errorf("unexpected the filtered log: %s", buf.String())
+	}
+
+	var r Record
+	hooks := NewHooks()
+	hooks.Add("panic", func(rr *Record) bool { r = *rr; return true })
+	logger = logger.WithHooks(hooks).WithCallerPC(true)
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeLevel("lvl"), EncodeCaller("caller"))
+
+	buf.Reset()
+	func() {
+		defer logger.RecoverAndLog(false, F("k", "v"))
+		var m map[string]int
+		m["a"] = 1
+	}()
+	if s := buf.String(); !strings.HasPrefix(s, "lvl=ERROR caller=logger_ext_test.go:96 panic=") ||
+		!strings.Contains(s, "stack=[logger_ext_test.go:96 ") ||
+		!strings.HasSuffix(s, " k=v msg=panic recovered\n") {
+		t.Errorf("unexpected the recovered panic: %s", s)
+	}
+	if r.Lvl != LvlError || len(r.Fields) != 3 {
+		t.Errorf("unexpected the record: %+v", r)
+	}
+
+	func() {
+		defer func() {
+			if v := recover(); v != "abc" {
+				t.Errorf("expect the panic 'abc', but got '%v'", v)
+			}
+		}()
+		defer logger.RecoverAndLog(true)
+		panic("abc")
+	}()
+
+	buf.Reset()
+	func() { defer logger.RecoverAndLog(true) }()
+	if buf.Len() != 0 {
+		t.Errorf("unexpected the log without panic: %s", buf.String())
+	}
+}
+
+func TestExtLoggerFatal(t *testing.T) {
+	var code int
+	var called bool
+	defer func(exit func(int), funcs []func()) { osExit, CallOnExit = exit, funcs }(osExit, CallOnExit)
+	osExit = func(c int) { code = c }
+	CallOnExit = []func(){func() { called = true }}
+
+	buf := NewBuilder(64)
+	logger := New("").WithEncoder(TextEncoder(StreamWriter(buf), EncodeLevel("lvl")))
+	logger.WithLevel(LvlPanic).Fatalf("fatal: %d", 1) // FATAL is filtered out.
+	if code != 1 || !called {
+		t.Errorf("expect to exit with 1 and call CallOnExit, but got %d and %v", code, called)
+	} else if buf.Len() != 0 {
+		t.Errorf("unexpected the filtered log: %s", buf.String())
+	}
+
+	code, called = 0, false
+	logger.Fatal("fatal")
+	if code != 1 || !called {
+		t.Errorf("expect to exit with 1 and call CallOnExit, but got %d and %v", code, called)
+	} else if expect := "lvl=FATAL msg=fatal\n"; buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
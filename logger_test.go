@@ -86,3 +86,22 @@ func TestFieldBuilder_Reset(t *testing.T) {
 		t.Error(fields)
 	}
 }
+
+func TestAtomicLevel(t *testing.T) {
+	buf := NewBuilder(128)
+	level := NewAtomicLevel(LvlWarn)
+	logger := New("").WithAtomicLevel(level).WithEncoder(TextEncoder(StreamWriter(buf)))
+	child := logger.WithName("child").WithCtx(F("key", "value"))
+
+	logger.Info("msg1")
+	child.Info("msg2")
+	level.SetLevel(LvlInfo)
+	logger.Info("msg3")
+	child.Info("msg4")
+
+	if s := buf.String(); s != "msg=msg3\nkey=value msg=msg4\n" {
+		t.Error(s)
+	} else if lvl := child.WithLevel(LvlError).GetLevel(); lvl != LvlError {
+		t.Error(lvl)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"strings"
+	"sync"
+)
+
+// DefaultRegistry is the default global registry based on DefalutLogger.
+var DefaultRegistry = NewRegistry(nil)
+
+// GetLogger is equal to DefaultRegistry.Logger(name).
+func GetLogger(name string) *ExtLogger { return DefaultRegistry.Logger(name) }
+
+// Registry is used to manage the loggers by the dotted name, such as "db.pool".
+//
+// The levels are configured by the name prefix, and the effective level
+// of the logger is inherited from the nearest configured ancestor. For example,
+// if the level of "db" is DEBUG, the loggers named "db", "db.pool" and
+// "db.pool.conn" will be DEBUG, but "dbx" is not.
+//
+// Registry has implemented the interface LevelStore.
+type Registry struct {
+	root    *ExtLogger
+	lock    sync.RWMutex
+	levels  map[string]Level
+	loggers map[string]*ExtLogger
+}
+
+// NewRegistry returns a new Registry, all the loggers of which are derived
+// from root. If root is nil, it is DefalutLogger when getting the logger.
+//
+// If the level of the root name "" is not configured, the level of root
+// will be used as the default.
+func NewRegistry(root *ExtLogger) *Registry {
+	return &Registry{
+		root:    root,
+		levels:  make(map[string]Level, 8),
+		loggers: make(map[string]*ExtLogger, 8),
+	}
+}
+
+func (r *Registry) getRoot() *ExtLogger {
+	if r.root == nil {
+		return DefalutLogger
+	}
+	return r.root
+}
+
+// Logger returns the logger by the name, which will be created and registered
+// if not exist. If calling WithName on the returned logger, the level
+// of the new logger will be resolved from the registry by the new name.
+func (r *Registry) Logger(name string) *ExtLogger {
+	r.lock.RLock()
+	logger, ok := r.loggers[name]
+	r.lock.RUnlock()
+	if ok {
+		return logger
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if logger, ok = r.loggers[name]; !ok {
+		logger = r.getRoot().Clone()
+		logger.Name = name
+		logger.registry = r
+		logger.AtomicLevel = NewAtomicLevel(r.effectiveLevel(name))
+		r.loggers[name] = logger
+	}
+	return logger
+}
+
+// atomicLevel returns the atomic level of the logger named name.
+func (r *Registry) atomicLevel(name string) *AtomicLevel {
+	return r.Logger(name).AtomicLevel
+}
+
+// EffectiveLevel returns the effective level of the logger named name.
+func (r *Registry) EffectiveLevel(name string) Level {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.effectiveLevel(name)
+}
+
+func (r *Registry) effectiveLevel(name string) Level {
+	for {
+		if level, ok := r.levels[name]; ok {
+			return level
+		} else if name == "" {
+			return r.getRoot().GetLevel()
+		}
+
+		if index := strings.LastIndexByte(name, '.'); index > -1 {
+			name = name[:index]
+		} else {
+			name = ""
+		}
+	}
+}
+
+// isDescendant reports whether the logger named name is the prefix itself
+// or the descendant of the prefix.
+func isDescendant(name, prefix string) bool {
+	return prefix == "" || name == prefix ||
+		(strings.HasPrefix(name, prefix) && name[len(prefix)] == '.')
+}
+
+func (r *Registry) updateLevels(prefix string) {
+	for name, logger := range r.loggers {
+		if isDescendant(name, prefix) {
+			logger.AtomicLevel.SetLevel(r.effectiveLevel(name))
+		}
+	}
+}
+
+// SetLevel sets the level of the name prefix, which will be inherited by all
+// the loggers whose names are name or start with "name.", unless the nearer
+// ancestor is configured. The empty name is the root of all the loggers.
+//
+// It has implemented the interface LevelStore.
+func (r *Registry) SetLevel(name string, level Level) error {
+	r.lock.Lock()
+	r.levels[name] = level
+	r.updateLevels(name)
+	r.lock.Unlock()
+	return nil
+}
+
+// UnsetLevel removes the configured level of the name prefix, so the loggers
+// will inherit the level from the ancestors.
+func (r *Registry) UnsetLevel(name string) {
+	r.lock.Lock()
+	delete(r.levels, name)
+	r.updateLevels(name)
+	r.lock.Unlock()
+}
+
+// ConfiguredLevel returns the level configured by the name prefix itself,
+// and false if it is not configured and inherited from the ancestors.
+func (r *Registry) ConfiguredLevel(name string) (level Level, ok bool) {
+	r.lock.RLock()
+	level, ok = r.levels[name]
+	r.lock.RUnlock()
+	return
+}
+
+// GetLevel returns the effective level of the logger named name,
+// which is always successful.
+//
+// It has implemented the interface LevelStore.
+func (r *Registry) GetLevel(name string) (Level, bool) {
+	return r.EffectiveLevel(name), true
+}
+
+// Levels returns the effective levels of all the configured name prefixes
+// and the registered loggers.
+//
+// It has implemented the interface LevelStore.
+func (r *Registry) Levels() map[string]Level {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	levels := make(map[string]Level, len(r.levels)+len(r.loggers))
+	for name, level := range r.levels {
+		levels[name] = level
+	}
+	for name, logger := range r.loggers {
+		levels[name] = logger.AtomicLevel.Level()
+	}
+	return levels
+}
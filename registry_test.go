@@ -0,0 +1,56 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import "testing"
+
+func TestRegistry(t *testing.T) {
+	buf := NewBuilder(128)
+	root := New("").WithLevel(LvlInfo).WithEncoder(TextEncoder(StreamWriter(buf), EncodeLogger("logger")))
+	registry := NewRegistry(root)
+
+	db := registry.Logger("db")
+	pool := registry.Logger("db.pool")
+	dbx := registry.Logger("dbx")
+	if registry.Logger("db") != db {
+		t.Error("the logger is not cached")
+	}
+
+	registry.SetLevel("db", LvlDebug)
+	db.Debug("msg1")
+	pool.Debug("msg2")
+	dbx.Debug("msg3")
+	pool.WithName("db.pool.conn").Debug("msg4")
+	db.WithName("api").Debug("msg5")
+
+	registry.SetLevel("db.pool", LvlWarn)
+	pool.Info("msg6")
+	db.Info("msg7")
+
+	registry.UnsetLevel("db")
+	db.Debug("msg8")
+	pool.Info("msg9")
+
+	expect := "logger=db msg=msg1\nlogger=db.pool msg=msg2\nlogger=db.pool.conn msg=msg4\nlogger=db msg=msg7\n"
+	if s := buf.String(); s != expect {
+		t.Error(s)
+	}
+
+	if lvl := registry.EffectiveLevel("db.pool.conn"); lvl != LvlWarn {
+		t.Error(lvl)
+	} else if lvl = registry.EffectiveLevel("a.b.c"); lvl != LvlInfo {
+		t.Error(lvl)
+	}
+}
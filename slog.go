@@ -0,0 +1,309 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build go1.21
+
+package klog
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// slogToLevel converts the level of log/slog to Level.
+func slogToLevel(lvl slog.Level) Level {
+	switch {
+	case lvl < slog.LevelDebug:
+		return LvlTrace
+	case lvl < slog.LevelInfo:
+		return LvlDebug
+	case lvl < slog.LevelWarn:
+		return LvlInfo
+	case lvl < slog.LevelError:
+		return LvlWarn
+	default:
+		return LvlError
+	}
+}
+
+// levelToSlog converts Level to the level of log/slog.
+func levelToSlog(lvl Level) slog.Level {
+	switch lvl {
+	case LvlTrace:
+		return slog.LevelDebug - 4
+	case LvlDebug:
+		return slog.LevelDebug
+	case LvlInfo:
+		return slog.LevelInfo
+	case LvlWarn:
+		return slog.LevelWarn
+	case LvlError:
+		return slog.LevelError
+	case LvlFatal:
+		return slog.LevelError + 4
+	default: // LvlPanic is above LvlFatal.
+		return slog.LevelError + 8
+	}
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// SlogHandler returns a new slog.Handler based on the logger, which converts
+// the slog records to Record, runs the hooks of the logger and encodes them
+// by the encoder of the logger.
+//
+// The attributes added by WithAttrs are appended into the contexts
+// of the logger, and WithGroup is equal to logger.WithGroup, which nests
+// the subsequent attributes by Namespace. The group attributes are converted
+// to Object, so they are encoded as the nested objects like SlogEncoder.
+// The fields carried by the context passed to the methods, such as
+// InfoContext, are added like LogCtx.
+//
+// Notice: the handler must be called by the methods of slog.Logger directly,
+// or the depth of the caller will be wrong. If it is wrapped by other handlers,
+// you should use logger.WithDepth to adjust it.
+func SlogHandler(logger *ExtLogger) slog.Handler {
+	return slogHandler{logger: logger}
+}
+
+type slogHandler struct {
+	logger *ExtLogger
+}
+
+func (h slogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return slogToLevel(lvl) >= h.logger.GetLevel()
+}
+
+func (h slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	var fields []Field
+	if n := r.NumAttrs(); n > 0 {
+		fields = make([]Field, 0, n)
+		r.Attrs(func(attr slog.Attr) bool {
+			fields = appendSlogAttr(fields, attr)
+			return true
+		})
+	}
+
+	ctxs := h.logger.Ctxs
+	if ctx != nil {
+		ctxs, fields = appendContextFields(ctx, ctxs, fields)
+	}
+
+	now := r.Time
+	if now.IsZero() {
+		now = h.logger.now()
+	}
+
+	// Handle <- slog.(*Logger).log <- slog.(*Logger).Info <- the caller
+	record := Record{
+		Name:   h.logger.Name,
+		Time:   now,
+		Depth:  h.logger.Depth + 3,
+		PC:     r.PC,
+		Lvl:    slogToLevel(r.Level),
+		Msg:    r.Message,
+		Ctxs:   ctxs,
+		Fields: fields,
+		Ctx:    ctx,
+
+		ctxsCache: h.logger.ctxsCache,
+	}
+	if record, ok := h.logger.Hooks.Run(record); ok {
+		h.logger.Encoder.Encode(record)
+	}
+	return nil
+}
+
+func (h slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	fields := make([]Field, 0, len(attrs))
+	for _, attr := range attrs {
+		fields = appendSlogAttr(fields, attr)
+	}
+	return slogHandler{logger: h.logger.WithCtx(fields...)}
+}
+
+func (h slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return slogHandler{logger: h.logger.WithGroup(name)}
+}
+
+func appendSlogAttr(fields []Field, attr slog.Attr) []Field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if attr.Value.Kind() != slog.KindGroup {
+		return append(fields, F(attr.Key, attr.Value.Any()))
+	}
+
+	attrs := attr.Value.Group()
+	if len(attrs) == 0 {
+		return fields
+	} else if attr.Key != "" {
+		return append(fields, Object(attr.Key, slogGroup(attrs)))
+	}
+
+	// Inline the attributes of the group without the key.
+	for _, a := range attrs {
+		fields = appendSlogAttr(fields, a)
+	}
+	return fields
+}
+
+// slogGroup is the attributes of the slog group encoded as the object.
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc ObjectEncoder) (err error) {
+	for _, attr := range g {
+		attr.Value = attr.Value.Resolve()
+		if attr.Equal(slog.Attr{}) {
+			continue
+		}
+
+		switch attr.Value.Kind() {
+		case slog.KindString:
+			enc.AddString(attr.Key, attr.Value.String())
+		case slog.KindInt64:
+			enc.AddInt64(attr.Key, attr.Value.Int64())
+		case slog.KindUint64:
+			enc.AddUint64(attr.Key, attr.Value.Uint64())
+		case slog.KindFloat64:
+			enc.AddFloat64(attr.Key, attr.Value.Float64())
+		case slog.KindBool:
+			enc.AddBool(attr.Key, attr.Value.Bool())
+		case slog.KindDuration:
+			enc.AddDuration(attr.Key, attr.Value.Duration())
+		case slog.KindTime:
+			enc.AddTime(attr.Key, attr.Value.Time())
+		case slog.KindGroup:
+			if attrs := attr.Value.Group(); len(attrs) == 0 {
+				continue
+			} else if attr.Key == "" {
+				err = slogGroup(attrs).MarshalLogObject(enc)
+			} else {
+				err = enc.AddObject(attr.Key, slogGroup(attrs))
+			}
+		default:
+			err = enc.AddAny(attr.Key, attr.Value.Any())
+		}
+
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// SlogEncoder returns a new Encoder, which will convert the record
+// to slog.Record and forward it into the slog handler.
+//
+// It only supports the option EncodeLogger, which will add the logger name
+// into the slog record as an attribute with the key.
+func SlogEncoder(handler slog.Handler, options ...EncoderOption) Encoder {
+	if handler == nil {
+		panic("SlogEncoder: the slog handler must not be nil")
+	}
+	return &slogEncoder{handler: handler, option: getOption(options...)}
+}
+
+type slogEncoder struct {
+	handler slog.Handler
+	option  option
+	writer  Writer
+}
+
+func (e *slogEncoder) Writer() Writer     { return e.writer }
+func (e *slogEncoder) SetWriter(w Writer) { e.writer = w }
+func (e *slogEncoder) Encode(r Record) {
+	r.Depth++
+
+	ctx := r.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	lvl := levelToSlog(r.Lvl)
+	if !e.handler.Enabled(ctx, lvl) {
+		return
+	}
+
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	sr := slog.NewRecord(r.Time, lvl, r.Msg, r.PC)
+	if r.Name != "" && e.option.LoggerKey != "" {
+		sr.AddAttrs(slog.String(e.option.LoggerKey, r.Name))
+	}
+
+	// The namespace in the contexts also nests the fields of the log.
+	ctxs, fields := r.Ctxs, r.Fields
+	if hasNamespace(ctxs) {
+		fields = append(append(make([]Field, 0, len(ctxs)+len(fields)), ctxs...), fields...)
+		ctxs = nil
+	}
+
+	attrs := make([]slog.Attr, 0, len(r.Ctxs)+len(r.Fields))
+	attrs = appendSlogAttrs(attrs, ctxs, r.Depth)
+	attrs = appendSlogAttrs(attrs, fields, r.Depth)
+	sr.AddAttrs(attrs...)
+
+	e.handler.Handle(ctx, sr)
+}
+
+func appendSlogAttrs(attrs []slog.Attr, fields []Field, depth int) []slog.Attr {
+	depth++
+	for i, field := range fields {
+		if f, ok := asTypedField(field); ok && f.typ == namespaceType {
+			group := appendSlogAttrs(nil, fields[i+1:], depth-1)
+			return append(attrs, slog.Attr{Key: f.key, Value: slog.GroupValue(group...)})
+		}
+
+		var value interface{}
+		if s, ok := field.(StackField); ok {
+			value = s.Stack(depth)
+		} else {
+			value = field.Value()
+		}
+
+		switch v := value.(type) {
+		case FieldError:
+			attrs = append(attrs, slog.String(field.Key(), v.Error()))
+			attrs = appendSlogAttrs(attrs, v.Fields(), depth-1)
+			v.Release()
+		case FieldReleaser:
+			attrs = appendSlogAttrs(attrs, v.Fields(), depth-1)
+			v.Release()
+		case error:
+			if v == nil {
+				attrs = append(attrs, slog.Any(field.Key(), nil))
+			} else {
+				attrs = append(attrs, slog.String(field.Key(), v.Error()))
+			}
+		default:
+			attrs = append(attrs, slog.Any(field.Key(), v))
+		}
+	}
+	return attrs
+}
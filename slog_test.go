@@ -0,0 +1,97 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build go1.21
+
+package klog
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	buf := NewBuilder(256)
+	logger := New("").WithCtx(Caller("caller")).WithLevel(LvlInfo)
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeLevel("lvl"))
+
+	slogger := slog.New(SlogHandler(logger))
+	slogger.Debug("debug")
+	slogger.Info("msg1", "k1", "v1", slog.Group("g", "k2", 123))
+	slogger.With("k3", "v3").WithGroup("g1").Warn("msg2", "k4", "v4")
+
+	expect := "lvl=INFO caller=slog_test.go:32 k1=v1 g.k2=123 msg=msg1\n" +
+		"lvl=WARN caller=slog_test.go:33 k3=v3 g1.k4=v4 msg=msg2\n"
+	if s := buf.String(); s != expect {
+		t.Error(s)
+	}
+
+	buf.Reset()
+	logger.Encoder = JSONEncoder(StreamWriter(buf), EncodeLevel("lvl"))
+	slogger = slog.New(SlogHandler(logger))
+	slogger.Info("msg1", "k1", "v1", slog.Group("g", "k2", 123, slog.Group("g2", "k3", true)), "k4", "v4")
+	slogger.With("k5", "v5").WithGroup("g1").With("k6", "v6").Warn("msg2", "k7", "v7")
+
+	expect = `{"lvl":"INFO","caller":"slog_test.go:44","k1":"v1","g":{"k2":123,"g2":{"k3":true}},"k4":"v4","msg":"msg1"}` + "\n" +
+		`{"lvl":"WARN","caller":"slog_test.go:45","k5":"v5","g1":{"k6":"v6","k7":"v7"},"msg":"msg2"}` + "\n"
+	if s := buf.String(); s != expect {
+		t.Error(s)
+	}
+
+	buf.Reset()
+	hooks := NewHooks()
+	hooks.Add("veto", func(r *Record) bool { return r.Msg != "veto" })
+	hooks.Add("enrich", func(r *Record) bool {
+		r.Fields = append(r.Fields, F("hook", true))
+		return true
+	})
+	logger.Encoder = TextEncoder(StreamWriter(buf), EncodeLevel("lvl"))
+	slogger = slog.New(SlogHandler(logger.WithHooks(hooks)))
+	slogger.Info("veto")
+	slogger.Info("msg")
+	if expect := "lvl=INFO caller=slog_test.go:63 hook=true msg=msg\n"; buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
+
+func TestSlogEncoder(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	handler := slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	logger := New("name").WithEncoder(SlogEncoder(handler, EncodeLogger("logger")))
+	logger = logger.WithCtx(Caller("caller"))
+	logger.Debug("debug")
+	logger.Info("msg", F("key", "value"))
+
+	expect := "level=INFO msg=msg logger=name caller=slog_test.go:84 key=value\n"
+	if s := buf.String(); s != expect {
+		t.Error(s)
+	}
+
+	buf.Reset()
+	logger.WithGroup("http").Info("msg", F("method", "GET"))
+	expect = "level=INFO msg=msg logger=name caller=slog_test.go:92 http.method=GET\n"
+	if s := buf.String(); s != expect {
+		t.Error(s)
+	}
+}
@@ -38,7 +38,7 @@ func (s syslogWriter) WriteLevel(level Level, p []byte) (n int, err error) {
 		err = s.w.Warning(v)
 	case LvlError:
 		err = s.w.Err(v)
-	case LvlFatal:
+	case LvlFatal, LvlPanic: // Emerg is the highest priority.
 		err = s.w.Emerg(v)
 	default:
 		err = s.w.Emerg(v)
@@ -0,0 +1,176 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+)
+
+// The keys of the trace fields emitted by TraceExtractor.
+const (
+	TraceIDKey    = "trace_id"
+	SpanIDKey     = "span_id"
+	TraceFlagsKey = "trace_flags"
+)
+
+// TraceParent is the W3C trace context of the span, see
+// https://www.w3.org/TR/trace-context/#traceparent-header.
+type TraceParent struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// ParseTraceParent parses the value of the W3C "traceparent" header,
+// such as "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func ParseTraceParent(s string) (tp TraceParent, err error) {
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' ||
+		(len(s) > 55 && s[55] != '-') || !isLowerHex(s[:55]) {
+		return tp, fmt.Errorf("invalid traceparent '%s'", s)
+	}
+
+	switch s[:2] {
+	case "00":
+		if len(s) != 55 {
+			return tp, fmt.Errorf("invalid traceparent '%s'", s)
+		}
+	case "ff":
+		return tp, fmt.Errorf("invalid traceparent version '%s'", s[:2])
+	}
+
+	var flags [1]byte
+	hex.Decode(tp.TraceID[:], []byte(s[3:35]))
+	hex.Decode(tp.SpanID[:], []byte(s[36:52]))
+	hex.Decode(flags[:], []byte(s[53:55]))
+	tp.Flags = flags[0]
+
+	if !tp.IsValid() {
+		return tp, fmt.Errorf("invalid traceparent '%s'", s)
+	}
+	return
+}
+
+func isLowerHex(s string) bool {
+	for i, c := range []byte(s) {
+		switch {
+		case i == 2 || i == 35 || i == 52:
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// IsValid reports whether both the trace id and the span id are not all zero.
+func (tp TraceParent) IsValid() bool {
+	return tp.TraceID != [16]byte{} && tp.SpanID != [8]byte{}
+}
+
+// Sampled reports whether the flag "sampled" is set.
+func (tp TraceParent) Sampled() bool { return tp.Flags&0x01 == 0x01 }
+
+// TraceIDString returns the lowercase hex string of the trace id.
+func (tp TraceParent) TraceIDString() string { return hex.EncodeToString(tp.TraceID[:]) }
+
+// SpanIDString returns the lowercase hex string of the span id.
+func (tp TraceParent) SpanIDString() string { return hex.EncodeToString(tp.SpanID[:]) }
+
+// FlagsString returns the lowercase hex string of the trace flags.
+func (tp TraceParent) FlagsString() string { return hex.EncodeToString([]byte{tp.Flags}) }
+
+// String returns the value of the W3C "traceparent" header with version "00".
+func (tp TraceParent) String() string {
+	buf := make([]byte, 0, 55)
+	buf = append(buf, "00-"...)
+	buf = appendHex(buf, tp.TraceID[:])
+	buf = append(buf, '-')
+	buf = appendHex(buf, tp.SpanID[:])
+	buf = append(buf, '-')
+	buf = appendHex(buf, []byte{tp.Flags})
+	return string(buf)
+}
+
+func appendHex(dst, src []byte) []byte {
+	const hextable = "0123456789abcdef"
+	for _, b := range src {
+		dst = append(dst, hextable[b>>4], hextable[b&0x0f])
+	}
+	return dst
+}
+
+// Fields returns the trace fields, that's, "trace_id", "span_id"
+// and "trace_flags", the values of which are the lowercase hex strings.
+func (tp TraceParent) Fields() []Field {
+	return tp.appendFields(make([]Field, 0, 3))
+}
+
+func (tp TraceParent) appendFields(fields []Field) []Field {
+	return append(fields,
+		F(TraceIDKey, tp.TraceIDString()),
+		F(SpanIDKey, tp.SpanIDString()),
+		F(TraceFlagsKey, tp.FlagsString()))
+}
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a new context carrying the trace parent,
+// which is used to carry the trace context without the tracing SDK,
+// such as parsing it from the request header "traceparent".
+func ContextWithTraceParent(ctx context.Context, tp TraceParent) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, tp)
+}
+
+// TraceParentFromContext returns the trace parent carried
+// by ContextWithTraceParent.
+func TraceParentFromContext(ctx context.Context) (tp TraceParent, ok bool) {
+	tp, ok = ctx.Value(traceParentKey{}).(TraceParent)
+	return
+}
+
+// TraceParentGetter is used to get the trace context of the active span
+// from the context, so klog does not depend on the tracing SDK. For example,
+// the adapter of OpenTelemetry is
+//
+//   func(ctx context.Context) (klog.TraceParent, bool) {
+//       sc := trace.SpanContextFromContext(ctx)
+//       return klog.TraceParent{
+//           TraceID: sc.TraceID(),
+//           SpanID:  sc.SpanID(),
+//           Flags:   byte(sc.TraceFlags()),
+//       }, sc.IsValid()
+//   }
+type TraceParentGetter func(ctx context.Context) (TraceParent, bool)
+
+// TraceExtractor returns a context extractor to add the trace fields
+// of the trace parent got by getter, which should be registered
+// by RegisterContextExtractor. See TraceParent.Fields.
+//
+// If getter is nil, it is TraceParentFromContext.
+func TraceExtractor(getter TraceParentGetter) ContextExtractor {
+	if getter == nil {
+		getter = TraceParentFromContext
+	}
+
+	return func(ctx context.Context, fields []Field) []Field {
+		if tp, ok := getter(ctx); ok && tp.IsValid() {
+			fields = tp.appendFields(fields)
+		}
+		return fields
+	}
+}
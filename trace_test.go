@@ -0,0 +1,92 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	const s = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tp, err := ParseTraceParent(s)
+	if err != nil {
+		t.Fatal(err)
+	} else if tp.String() != s {
+		t.Errorf("expect '%s', but got '%s'", s, tp.String())
+	} else if !tp.Sampled() {
+		t.Error("expect sampled")
+	}
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+	} {
+		if _, err := ParseTraceParent(s); err == nil {
+			t.Errorf("expect an error for '%s'", s)
+		}
+	}
+
+	if _, err := ParseTraceParent("01" + s[2:] + "-future"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTraceExtractor(t *testing.T) {
+	defer func(extractors []ContextExtractor) { ContextExtractors = extractors }(ContextExtractors)
+	RegisterContextExtractor(TraceExtractor(nil))
+
+	tp, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithTraceParent(context.Background(), tp)
+
+	buf := NewBuilder(128)
+	logger := New("")
+	logger.Encoder = TextEncoder(StreamWriter(buf))
+	logger.InfoCtx(ctx, "msg")
+	logger.InfoCtx(context.Background(), "msg")
+
+	expect := "trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7 trace_flags=01 msg=msg\nmsg=msg\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	buf.Reset()
+	logger.Encoder = JSONEncoder(StreamWriter(buf))
+	logger.InfoCtx(ctx, "msg")
+
+	expect = `{"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","trace_flags":"01","msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+
+	// The trace fields are always at the top level.
+	buf.Reset()
+	ctx = ContextWithFields(ctx, F("user", "abc"))
+	logger.WithGroup("http").WithCtx(F("path", "/")).InfoCtx(ctx, "msg", F("method", "GET"))
+	logger.InfoCtx(ctx, "msg", Namespace("req"), F("id", 1))
+
+	expect = `{"user":"abc","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7",` +
+		`"trace_flags":"01","http":{"path":"/","method":"GET"},"msg":"msg"}` + "\n" +
+		`{"user":"abc","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7",` +
+		`"trace_flags":"01","req":{"id":1},"msg":"msg"}` + "\n"
+	if buf.String() != expect {
+		t.Errorf("expect '%s', but got '%s'", expect, buf.String())
+	}
+}
@@ -25,7 +25,7 @@ var BuilderSize = 256
 var builderPool = sync.Pool{New: func() interface{} { return NewBuilder(BuilderSize) }}
 
 func getBuilder() *Builder  { return builderPool.Get().(*Builder) }
-func putBuilder(b *Builder) { b.Reset(); builderPool.Put(b) }
+func putBuilder(b *Builder) { b.Reset(); b.escapeHTML = false; builderPool.Put(b) }
 
 // ParseSize parses the size string. The size maybe have a unit suffix,
 // such as "123", "123M, 123G". Valid size units are "b", "B", "k", "K",
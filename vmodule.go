@@ -0,0 +1,165 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type vmoduleRule struct {
+	pattern string
+	level   Level
+}
+
+// VModule is used to enable the lower level only for the call sites
+// whose file or package matches the pattern, like the glog flag "-vmodule".
+//
+// The decision is cached by the program counter of the call site,
+// so it is cheap for the call sites that have been checked.
+type VModule struct {
+	rules []vmoduleRule
+
+	lock  sync.RWMutex
+	cache map[uintptr]int
+}
+
+// ParseVModule parses the vmodule spec and returns a new VModule.
+//
+// The format of spec is a comma-separated list of "pattern=LEVEL", such as
+// "pool=debug,db/*=trace". LEVEL is the level name supported by NameToLevel
+// or the level number. The pattern is a glob pattern supported by path.Match:
+//
+//   - If the pattern contains "/", it is matched against the trailing path
+//     components of the package import path or the file without the suffix
+//     ".go", such as "db/*" matching "/path/to/db/pool.go".
+//   - Or, it is matched against the file basename without the suffix ".go"
+//     or the package name, such as "pool" matching "/path/to/db/pool.go".
+//
+// The first matched pattern wins.
+func ParseVModule(spec string) (*VModule, error) {
+	m := &VModule{cache: make(map[uintptr]int, 16)}
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		index := strings.IndexByte(item, '=')
+		if index < 1 {
+			return nil, fmt.Errorf("invalid vmodule item '%s'", item)
+		}
+
+		pattern := strings.TrimSpace(item[:index])
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid vmodule pattern '%s': %s", pattern, err)
+		}
+
+		value := strings.TrimSpace(item[index+1:])
+		level, err := ParseLevel(value)
+		if err != nil {
+			v, e := strconv.ParseUint(value, 10, 8)
+			if e != nil {
+				return nil, fmt.Errorf("invalid vmodule level '%s'", value)
+			}
+			level = Level(v)
+		}
+
+		m.rules = append(m.rules, vmoduleRule{pattern: pattern, level: level})
+	}
+	return m, nil
+}
+
+// Enabled reports whether the log with the level is enabled for the call site
+// by the vmodule rules.
+//
+// depth is the stack depth of the call site, and 0 identifies the caller
+// of Enabled.
+func (m *VModule) Enabled(lvl Level, depth int) bool {
+	if m == nil || len(m.rules) == 0 {
+		return false
+	}
+	return m.EnabledPC(lvl, callerPC(depth+1))
+}
+
+// EnabledPC is the same as Enabled, but uses the program counter
+// of the call site returned by runtime.Callers, such as Record.PC.
+func (m *VModule) EnabledPC(lvl Level, pc uintptr) bool {
+	if m == nil || len(m.rules) == 0 || pc == 0 {
+		return false
+	}
+
+	m.lock.RLock()
+	level, ok := m.cache[pc]
+	m.lock.RUnlock()
+
+	if !ok {
+		frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+		level = m.match(frame)
+		m.lock.Lock()
+		m.cache[pc] = level
+		m.lock.Unlock()
+	}
+
+	return level > -1 && int(lvl) >= level
+}
+
+// match returns the level of the first matched rule, or -1.
+func (m *VModule) match(frame runtime.Frame) int {
+	file := strings.TrimSuffix(frame.File, ".go")
+	pkg := funcPackage(frame.Function)
+	for _, rule := range m.rules {
+		if n := strings.Count(rule.pattern, "/"); n > 0 {
+			if matchPath(rule.pattern, lastPathComponents(pkg, n+1)) ||
+				matchPath(rule.pattern, lastPathComponents(file, n+1)) {
+				return int(rule.level)
+			}
+		} else if matchPath(rule.pattern, path.Base(file)) ||
+			matchPath(rule.pattern, path.Base(pkg)) {
+			return int(rule.level)
+		}
+	}
+	return -1
+}
+
+func matchPath(pattern, name string) bool {
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// funcPackage returns the import path of the package from the full function
+// name, such as "github.com/xgfone/klog/v4.(*ExtLogger).Log".
+func funcPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot > -1 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// lastPathComponents returns the last n components of the path.
+func lastPathComponents(p string, n int) string {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i] == '/' {
+			if n--; n == 0 {
+				return p[i+1:]
+			}
+		}
+	}
+	return p
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestVModule(t *testing.T) {
+	vm, err := ParseVModule("vmodule_test=debug, klog/*=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := NewBuilder(128)
+	logger := New("").WithLevel(LvlWarn).WithEncoder(TextEncoder(StreamWriter(buf), EncodeLevel("lvl")))
+	logger = logger.WithVModule(vm)
+	for i := 0; i < 2; i++ {
+		logger.Trace("msg1")
+		logger.Debug("msg2")
+	}
+
+	if s := buf.String(); s != "lvl=DEBUG msg=msg2\nlvl=DEBUG msg=msg2\n" {
+		t.Error(s)
+	} else if len(vm.cache) != 2 {
+		t.Errorf("expected 2 cached call sites, but got %d", len(vm.cache))
+	}
+
+	frame := runtime.Frame{
+		File:     "/path/to/github.com/xgfone/klog/v4/logger.go",
+		Function: "github.com/xgfone/klog/v4.(*ExtLogger).Log",
+	}
+	if lvl := vm.match(frame); lvl != 1 {
+		t.Error(lvl)
+	}
+
+	if _, err := ParseVModule("db=unknown"); err == nil {
+		t.Error("expected an error, but got nil")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 var fileFlag = os.O_CREATE | os.O_APPEND | os.O_WRONLY
@@ -40,6 +41,7 @@ type writerLevel interface {
 type writerFunc struct {
 	write func(Level, []byte) (int, error)
 	close func() error
+	inner Writer // The writer wrapped by SafeWriter or LevelWriter
 }
 
 func (w writerFunc) WriteLevel(l Level, p []byte) (int, error) { return w.write(l, p) }
@@ -51,7 +53,7 @@ func WriterFunc(write func(Level, []byte) (int, error), close ...func() error) W
 	if len(close) != 0 {
 		closer = close[0]
 	}
-	return writerFunc{write, closer}
+	return writerFunc{write: write, close: closer}
 }
 
 //////////////////////////////////////////////////////////////////////////////
@@ -107,12 +109,13 @@ func DiscardWriter() Writer {
 
 // LevelWriter filters the logs whose level is less than lvl.
 func LevelWriter(lvl Level, w Writer) Writer {
-	return WriterFunc(func(level Level, p []byte) (n int, err error) {
+	write := func(level Level, p []byte) (n int, err error) {
 		if level >= lvl {
 			n, err = w.WriteLevel(level, p)
 		}
 		return
-	}, w.Close)
+	}
+	return writerFunc{write: write, close: w.Close, inner: w}
 }
 
 // SafeWriter is guaranteed that only a single writing operation can proceed
@@ -121,11 +124,12 @@ func LevelWriter(lvl Level, w Writer) Writer {
 // It's necessary for thread-safe concurrent writes.
 func SafeWriter(w Writer) Writer {
 	var mu sync.Mutex
-	return WriterFunc(func(level Level, p []byte) (int, error) {
+	write := func(level Level, p []byte) (int, error) {
 		mu.Lock()
 		defer mu.Unlock()
 		return w.WriteLevel(level, p)
-	}, w.Close)
+	}
+	return writerFunc{write: write, close: w.Close, inner: w}
 }
 
 // BufferWriter returns a new Writer to write all logs to a buffer
@@ -216,7 +220,7 @@ func FileWriter(filename, filesize string, filenum int) (Writer, error) {
 	return StreamWriter(w), nil
 }
 
-// NewSizedRotatingFile returns a new SizedRotatingFile, which is not thread-safe.
+// NewSizedRotatingFile returns a new SizedRotatingFile, which is thread-safe.
 //
 // The default permission of the log file is 0644.
 func NewSizedRotatingFile(filename string, size, count int,
@@ -240,23 +244,60 @@ func NewSizedRotatingFile(filename string, size, count int,
 }
 
 // SizedRotatingFile is a file rotating logging writer based on the size.
+//
+// The backup files are named "filename.1", "filename.2", etc, and the greater
+// the number is, the older the backup file is. If compressing the backup files,
+// they are named "filename.1.gz", "filename.2.gz", etc.
 type SizedRotatingFile struct {
+	FileRetention
+
 	file        *os.File
 	filePerm    os.FileMode
 	filename    string
 	maxSize     int
 	backupCount int
 	nbytes      int
+	task        backgroundTask
+	lock        sync.Mutex
 }
 
-// Close implements io.Closer.
-func (f *SizedRotatingFile) Close() error { return f.close() }
+// Close implements io.Closer, which will wait until the background task
+// handling the backup files finishes.
+func (f *SizedRotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.task.wait()
+	return f.close()
+}
 
 // Flush flushes the data to the underlying disk.
-func (f *SizedRotatingFile) Flush() error { return f.file.Sync() }
+func (f *SizedRotatingFile) Flush() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Sync()
+}
+
+// Reopen reopens the log file, which is used to cooperate with the external
+// log rotation tool, such as logrotate. If failing, the original file
+// is still used.
+func (f *SizedRotatingFile) Reopen() (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return errors.New("the file has been closed")
+	}
+
+	old := f.file
+	if err = f.open(); err != nil {
+		return
+	}
+	return old.Close()
+}
 
 // Write implements io.Writer.
 func (f *SizedRotatingFile) Write(data []byte) (n int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	if f.file == nil {
 		return 0, errors.New("the file has been closed")
 	}
@@ -283,6 +324,7 @@ func (f *SizedRotatingFile) open() (err error) {
 
 	info, err := file.Stat()
 	if err != nil {
+		file.Close()
 		return
 	}
 
@@ -312,24 +354,35 @@ func (f *SizedRotatingFile) doRollover() (err error) {
 			return nil
 		}
 
+		// Prevent the backup files from being handled by the background task.
+		f.task.files.Lock()
+		defer f.task.files.Unlock()
+
 		for _, i := range ranges(f.backupCount-1, 0, -1) {
 			sfn := fmt.Sprintf("%s.%d", f.filename, i)
 			dfn := fmt.Sprintf("%s.%d", f.filename, i+1)
-			if fileIsExist(sfn) {
-				if fileIsExist(dfn) {
-					os.Remove(dfn)
-				}
-				if err = os.Rename(sfn, dfn); err != nil {
-					return fmt.Errorf("failed to rename the rotating file '%s' to '%s': %s",
-						sfn, dfn, err)
+			if !backupIsExist(sfn) {
+				continue
+			}
+
+			// Remove both the uncompressed and compressed destinations first,
+			// which may be left when the source has only one of them.
+			if err = removeBackup(dfn); err != nil {
+				return
+			}
+
+			for _, ext := range []string{"", compressSuffix} {
+				if fileIsExist(sfn + ext) {
+					if err = os.Rename(sfn+ext, dfn+ext); err != nil {
+						return fmt.Errorf("failed to rename the rotating file '%s' to '%s': %s",
+							sfn+ext, dfn+ext, err)
+					}
 				}
 			}
 		}
 		dfn := f.filename + ".1"
-		if fileIsExist(dfn) {
-			if err = os.Remove(dfn); err != nil {
-				return fmt.Errorf("failed to remove the rotating file '%s': %s", dfn, err)
-			}
+		if err = removeBackup(dfn); err != nil {
+			return
 		}
 		if fileIsExist(f.filename) {
 			if err = os.Rename(f.filename, dfn); err != nil {
@@ -337,7 +390,57 @@ func (f *SizedRotatingFile) doRollover() (err error) {
 					f.filename, dfn, err)
 			}
 		}
-		err = f.open()
+		if err = f.open(); err == nil {
+			f.handleBackups()
+		}
+	}
+	return
+}
+
+// backups returns all the backup files sorted from oldest to newest.
+func (f *SizedRotatingFile) backups() (files []backupFile) {
+	for _, i := range ranges(f.backupCount, 0, -1) {
+		for _, ext := range []string{"", compressSuffix} {
+			name := fmt.Sprintf("%s.%d%s", f.filename, i, ext)
+			if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+				files = append(files, backupFile{
+					name:    name,
+					size:    info.Size(),
+					modTime: info.ModTime(),
+				})
+			}
+		}
+	}
+	return
+}
+
+// handleBackups compresses and prunes the backup files in the background.
+func (f *SizedRotatingFile) handleBackups() {
+	if !f.Compress && f.MaxAge <= 0 && f.MaxTotalSize <= 0 {
+		return
+	}
+
+	retention := f.FileRetention
+	f.task.run(func() {
+		f.task.files.Lock()
+		files := f.backups()
+		f.task.files.Unlock()
+		retention.compressBackups(files, &f.task.files)
+
+		f.task.files.Lock()
+		defer f.task.files.Unlock()
+		retention.prune(f.backups(), time.Now())
+	})
+}
+
+// removeBackup removes both the uncompressed and compressed backup file.
+func removeBackup(name string) (err error) {
+	for _, name := range []string{name, name + compressSuffix} {
+		if fileIsExist(name) {
+			if err = os.Remove(name); err != nil {
+				return fmt.Errorf("failed to remove the rotating file '%s': %s", name, err)
+			}
+		}
 	}
 	return
 }
@@ -0,0 +1,237 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrAsyncWriterClosed is returned when writing the data into the closed
+// AsyncWriter.
+var ErrAsyncWriterClosed = errors.New("the async writer has been closed")
+
+// OverflowPolicy is the policy how to handle the new log when the queue
+// of AsyncWriter is full.
+type OverflowPolicy uint8
+
+// Predefine some overflow policies.
+const (
+	// OverflowBlock blocks the writing until the queue is not full.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest drops the new log.
+	OverflowDropNewest
+
+	// OverflowDropOldest drops the oldest log in the queue.
+	OverflowDropOldest
+
+	// OverflowDropBelowLevel drops the new log if its level is lower than
+	// the given level, or blocks the writing.
+	OverflowDropBelowLevel
+)
+
+type asyncEntry struct {
+	level Level
+	data  []byte
+}
+
+// AsyncWriter is a writer to write the logs into the wrapped writer
+// asynchronously by a background goroutine, which uses a bounded ring buffer
+// as the queue.
+type AsyncWriter struct {
+	dropped uint64 // Must be the first field for the 64-bit alignment
+	failed  uint64
+
+	writer Writer
+	policy OverflowPolicy
+	level  Level
+
+	lock     sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	queue    []asyncEntry
+	head     int
+	size     int
+	closed   bool
+	discard  bool
+	exited   bool
+
+	done chan struct{}
+}
+
+// NewAsyncWriter returns a new AsyncWriter, which will start a background
+// goroutine to write the logs in the queue into w.
+//
+// size is the capacity of the queue. If it is 0 or negative, it will be
+// reset to 1024.
+//
+// level is only used by the policy OverflowDropBelowLevel, which is LvlWarn
+// by default.
+func NewAsyncWriter(w Writer, size int, policy OverflowPolicy, level ...Level) *AsyncWriter {
+	if size < 1 {
+		size = 1024
+	}
+
+	lvl := LvlWarn
+	if len(level) > 0 {
+		lvl = level[0]
+	}
+
+	aw := &AsyncWriter{
+		writer: w,
+		policy: policy,
+		level:  lvl,
+		queue:  make([]asyncEntry, size),
+		done:   make(chan struct{}),
+	}
+	aw.notEmpty = sync.NewCond(&aw.lock)
+	aw.notFull = sync.NewCond(&aw.lock)
+
+	go aw.loop()
+	return aw
+}
+
+// Dropped returns the number of the dropped logs.
+func (w *AsyncWriter) Dropped() uint64 { return atomic.LoadUint64(&w.dropped) }
+
+// Failed returns the number of the logs which failed to be written
+// into the wrapped writer.
+func (w *AsyncWriter) Failed() uint64 { return atomic.LoadUint64(&w.failed) }
+
+// WriteLevel implements the interface Writer, which will copy the data
+// and put it into the queue.
+func (w *AsyncWriter) WriteLevel(level Level, data []byte) (n int, err error) {
+	w.lock.Lock()
+	for w.size == len(w.queue) && !w.closed {
+		switch w.policy {
+		case OverflowDropNewest:
+			w.lock.Unlock()
+			atomic.AddUint64(&w.dropped, 1)
+			return len(data), nil
+
+		case OverflowDropOldest:
+			w.queue[w.head] = asyncEntry{}
+			w.head = (w.head + 1) % len(w.queue)
+			w.size--
+			atomic.AddUint64(&w.dropped, 1)
+
+		case OverflowDropBelowLevel:
+			if level < w.level {
+				w.lock.Unlock()
+				atomic.AddUint64(&w.dropped, 1)
+				return len(data), nil
+			}
+			w.notFull.Wait()
+
+		default:
+			w.notFull.Wait()
+		}
+	}
+
+	if w.closed {
+		w.lock.Unlock()
+		return 0, ErrAsyncWriterClosed
+	}
+
+	// The data may be the bytes of the pooled Builder, so copy it.
+	buf := make([]byte, len(data))
+	copy(buf, data)
+
+	w.queue[(w.head+w.size)%len(w.queue)] = asyncEntry{level: level, data: buf}
+	w.size++
+	w.notEmpty.Signal()
+	w.lock.Unlock()
+
+	return len(data), nil
+}
+
+// Close is equal to w.CloseTimeout(0).
+func (w *AsyncWriter) Close() error { return w.CloseTimeout(0) }
+
+// CloseTimeout closes the writer, which will wait until all the logs
+// in the queue are written into the wrapped writer, then close it.
+//
+// If timeout is greater than 0 and the queue has not been drained
+// in the duration, the rest logs will be discarded and it returns an error.
+// In this case, the wrapped writer will be closed by the background goroutine
+// after the log being written is finished.
+func (w *AsyncWriter) CloseTimeout(timeout time.Duration) (err error) {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return ErrAsyncWriterClosed
+	}
+	w.closed = true
+	w.notEmpty.Broadcast()
+	w.notFull.Broadcast()
+	w.lock.Unlock()
+
+	if timeout <= 0 {
+		<-w.done
+		return w.writer.Close()
+	}
+
+	timer := time.NewTimer(timeout)
+	select {
+	case <-w.done:
+		timer.Stop()
+		return w.writer.Close()
+	case <-timer.C:
+		w.lock.Lock()
+		if w.exited {
+			w.lock.Unlock()
+			return w.writer.Close()
+		}
+		w.discard = true
+		w.lock.Unlock()
+		return errors.New("timeout to drain the queue of the async writer")
+	}
+}
+
+func (w *AsyncWriter) loop() {
+	defer close(w.done)
+	for {
+		w.lock.Lock()
+		for w.size == 0 && !w.closed {
+			w.notEmpty.Wait()
+		}
+
+		if w.discard {
+			atomic.AddUint64(&w.dropped, uint64(w.size))
+			w.size = 0
+			w.lock.Unlock()
+			w.writer.Close()
+			return
+		} else if w.size == 0 {
+			w.exited = true
+			w.lock.Unlock()
+			return
+		}
+
+		entry := w.queue[w.head]
+		w.queue[w.head] = asyncEntry{}
+		w.head = (w.head + 1) % len(w.queue)
+		w.size--
+		w.notFull.Signal()
+		w.lock.Unlock()
+
+		if _, err := w.writer.WriteLevel(entry.level, entry.data); err != nil {
+			atomic.AddUint64(&w.failed, 1)
+		}
+	}
+}
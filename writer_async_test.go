@@ -0,0 +1,187 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	buf := NewBuilder(128)
+	w := NewAsyncWriter(StreamWriter(buf), 16, OverflowBlock)
+	logger := New("").WithEncoder(TextEncoder(w))
+	logger.Info("msg1")
+	logger.Info("msg2", F("key", "value"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := buf.String(); s != "msg=msg1\nkey=value msg=msg2\n" {
+		t.Error(s)
+	} else if _, err := w.WriteLevel(LvlInfo, []byte("msg")); err != ErrAsyncWriterClosed {
+		t.Error(err)
+	}
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{}, 1)
+	buf := NewBuilder(128)
+	w := NewAsyncWriter(WriterFunc(func(l Level, p []byte) (int, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-block
+		return buf.Write(p)
+	}), 2, OverflowDropOldest)
+
+	// The first is taken by the background goroutine, and blocked.
+	w.WriteLevel(LvlInfo, []byte("1\n"))
+	<-started
+
+	for _, s := range []string{"2\n", "3\n", "4\n", "5\n"} {
+		w.WriteLevel(LvlInfo, []byte(s))
+	}
+	close(block)
+	w.Close()
+
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("expected 2 dropped logs, but got %d", n)
+	} else if s := strings.Replace(buf.String(), "\n", " ", -1); s != "1 4 5 " {
+		t.Error(s)
+	}
+}
+
+// blockedWriter is a writer blocked on writing until unblock is called.
+type blockedWriter struct {
+	buf     *Builder
+	block   chan struct{}
+	started chan struct{}
+	closed  chan struct{}
+}
+
+func newBlockedWriter() *blockedWriter {
+	return &blockedWriter{
+		buf:     NewBuilder(128),
+		block:   make(chan struct{}),
+		started: make(chan struct{}, 1),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (w *blockedWriter) unblock()       { close(w.block) }
+func (w *blockedWriter) Close() error   { close(w.closed); return nil }
+func (w *blockedWriter) String() string { return strings.Replace(w.buf.String(), "\n", " ", -1) }
+func (w *blockedWriter) WriteLevel(l Level, p []byte) (int, error) {
+	select {
+	case w.started <- struct{}{}:
+	default:
+	}
+	<-w.block
+	return w.buf.Write(p)
+}
+
+func TestAsyncWriterDropNewest(t *testing.T) {
+	bw := newBlockedWriter()
+	w := NewAsyncWriter(bw, 2, OverflowDropNewest)
+
+	// The first is taken by the background goroutine, and blocked.
+	w.WriteLevel(LvlInfo, []byte("1\n"))
+	<-bw.started
+
+	for _, s := range []string{"2\n", "3\n", "4\n", "5\n"} {
+		w.WriteLevel(LvlInfo, []byte(s))
+	}
+	bw.unblock()
+	w.Close()
+
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("expected 2 dropped logs, but got %d", n)
+	} else if s := bw.String(); s != "1 2 3 " {
+		t.Error(s)
+	}
+}
+
+func TestAsyncWriterDropBelowLevel(t *testing.T) {
+	bw := newBlockedWriter()
+	w := NewAsyncWriter(bw, 2, OverflowDropBelowLevel, LvlWarn)
+
+	w.WriteLevel(LvlInfo, []byte("1\n"))
+	<-bw.started
+
+	// The queue is full after the second and third.
+	for _, s := range []string{"2\n", "3\n", "4\n", "5\n"} {
+		w.WriteLevel(LvlInfo, []byte(s))
+	}
+
+	// The high-level log is blocked until the queue is not full.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.WriteLevel(LvlWarn, []byte("6\n"))
+	}()
+
+	select {
+	case <-done:
+		t.Error("the high-level log is not blocked")
+	case <-time.After(time.Millisecond * 10):
+	}
+
+	bw.unblock()
+	<-done
+	w.Close()
+
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("expected 2 dropped logs, but got %d", n)
+	} else if s := bw.String(); s != "1 2 3 6 " {
+		t.Error(s)
+	}
+}
+
+func TestAsyncWriterCloseTimeout(t *testing.T) {
+	bw := newBlockedWriter()
+	w := NewAsyncWriter(bw, 4, OverflowBlock)
+
+	w.WriteLevel(LvlInfo, []byte("1\n"))
+	<-bw.started
+	w.WriteLevel(LvlInfo, []byte("2\n"))
+	w.WriteLevel(LvlInfo, []byte("3\n"))
+
+	if err := w.CloseTimeout(time.Millisecond * 10); err == nil {
+		t.Error("expected a timeout error, but got nil")
+	} else if _, err := w.WriteLevel(LvlError, []byte("4\n")); err != ErrAsyncWriterClosed {
+		t.Errorf("expected ErrAsyncWriterClosed, but got %v", err)
+	}
+
+	// The rest logs are discarded and the wrapped writer is still closed
+	// after the log being written is finished.
+	bw.unblock()
+	select {
+	case <-bw.closed:
+	case <-time.After(time.Second):
+		t.Fatal("the wrapped writer is not closed")
+	}
+
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("expected 2 dropped logs, but got %d", n)
+	} else if s := bw.String(); s != "1 " {
+		t.Error(s)
+	} else if err := w.Close(); err != ErrAsyncWriterClosed {
+		t.Errorf("expected ErrAsyncWriterClosed, but got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// Reopener is used to reopen the file, which is used to cooperate with
+// the external log rotation tool, such as logrotate with "create".
+type Reopener interface {
+	Reopen() error
+}
+
+var (
+	reopenLock sync.Mutex
+	reopeners  []Reopener
+)
+
+// RegisterReopener registers the reopener, which will be reopened
+// by ReopenFiles.
+func RegisterReopener(r Reopener) {
+	reopenLock.Lock()
+	reopeners = append(reopeners, r)
+	reopenLock.Unlock()
+}
+
+// UnregisterReopener unregisters the reopener.
+func UnregisterReopener(r Reopener) {
+	reopenLock.Lock()
+	defer reopenLock.Unlock()
+	for i, _len := 0, len(reopeners); i < _len; i++ {
+		if reopeners[i] == r {
+			copy(reopeners[i:], reopeners[i+1:])
+			reopeners[_len-1] = nil
+			reopeners = reopeners[:_len-1]
+			return
+		}
+	}
+}
+
+// ReopenFiles reopens all the registered reopeners, and returns the first error.
+func ReopenFiles() (err error) {
+	reopenLock.Lock()
+	defer reopenLock.Unlock()
+	for _, r := range reopeners {
+		if e := r.Reopen(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// ReopenOnSignal starts a goroutine to listen on the signals, and reopens
+// all the registered reopeners by ReopenFiles when receiving any signal.
+// It returns a function to stop listening on the signals.
+//
+// If sigs is empty, it is SIGHUP and SIGUSR1 on Unix by default,
+// and it does nothing on other platforms.
+//
+// Notice: the error to reopen the files will be written into os.Stderr.
+func ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		if sigs = defaultReopenSignals; len(sigs) == 0 {
+			return func() {}
+		}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ch:
+				if err := ReopenFiles(); err != nil {
+					fmt.Fprintf(os.Stderr, "klog: failed to reopen the files: %s\n", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { signal.Stop(ch); close(done) }) }
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// NewReopenableFile returns a new ReopenableFile, which is thread-safe.
+//
+// The default permission of the log file is 0644.
+func NewReopenableFile(filename string, mode ...os.FileMode) (*ReopenableFile, error) {
+	var _mode os.FileMode = 0644
+	if len(mode) > 0 && mode[0] > 0 {
+		_mode = mode[0]
+	}
+
+	file, err := os.OpenFile(filename, fileFlag, _mode)
+	if err != nil {
+		return nil, err
+	}
+	return &ReopenableFile{file: file, filename: filename, filePerm: _mode}, nil
+}
+
+// ReopenableFile is a file logging writer, which can be reopened, for example,
+// after the file is moved by logrotate.
+type ReopenableFile struct {
+	lock     sync.Mutex
+	file     *os.File
+	filename string
+	filePerm os.FileMode
+}
+
+// Reopen reopens the log file. If failing, the original file is still used.
+func (f *ReopenableFile) Reopen() error {
+	file, err := os.OpenFile(f.filename, fileFlag, f.filePerm)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	if f.file == nil {
+		f.lock.Unlock()
+		file.Close()
+		return errors.New("the file has been closed")
+	}
+	old := f.file
+	f.file = file
+	f.lock.Unlock()
+
+	return old.Close()
+}
+
+// Close implements io.Closer.
+func (f *ReopenableFile) Close() (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	return
+}
+
+// Flush flushes the data to the underlying disk.
+func (f *ReopenableFile) Flush() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Sync()
+}
+
+// Write implements io.Writer.
+func (f *ReopenableFile) Write(data []byte) (n int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return 0, errors.New("the file has been closed")
+	}
+	return f.file.Write(data)
+}
+
+// WriteLevel implements the interface Writer.
+func (f *ReopenableFile) WriteLevel(level Level, data []byte) (int, error) {
+	return f.Write(data)
+}
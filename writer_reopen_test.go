@@ -0,0 +1,108 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReopenFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename1 := filepath.Join(dir, "test1.log")
+	f1, err := NewReopenableFile(filename1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f1.Close()
+
+	filename2 := filepath.Join(dir, "test2.log")
+	f2, err := NewSizedRotatingFile(filename2, 1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f2.Close()
+
+	RegisterReopener(f1)
+	RegisterReopener(f2)
+	defer UnregisterReopener(f1)
+	defer UnregisterReopener(f2)
+
+	f1.Write([]byte("1\n"))
+	f2.Write([]byte("1\n"))
+	os.Rename(filename1, filename1+".old")
+	os.Rename(filename2, filename2+".old")
+
+	if err := ReopenFiles(); err != nil {
+		t.Fatal(err)
+	}
+	f1.Write([]byte("2\n"))
+	f2.Write([]byte("2\n"))
+
+	for _, name := range []string{filename1, filename2} {
+		if s := readFile(t, name); s != "2\n" {
+			t.Errorf("%s: %s", name, s)
+		} else if s := readFile(t, name+".old"); s != "1\n" {
+			t.Errorf("%s: %s", name+".old", s)
+		}
+	}
+}
+
+func TestReopenFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	subdir := filepath.Join(dir, "logs")
+	os.MkdirAll(subdir, 0755)
+
+	f1, err := NewSizedRotatingFile(filepath.Join(subdir, "test1.log"), 1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f1.Close()
+
+	f2, err := NewTimedRotatingFile(filepath.Join(subdir, "test2.log"), time.Hour, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f2.Close()
+
+	// Let the files fail to be reopened.
+	if err := os.RemoveAll(subdir); err != nil {
+		t.Skip(err)
+	}
+
+	for _, f := range []interface {
+		Reopener
+		Write([]byte) (int, error)
+	}{f1, f2} {
+		if err := f.Reopen(); err == nil {
+			t.Errorf("%T: expect an error to reopen the file", f)
+		} else if _, err := f.Write([]byte("1\n")); err != nil {
+			t.Errorf("%T: unexpected the error after failing to reopen: %s", f, err)
+		}
+	}
+}
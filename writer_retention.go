@@ -0,0 +1,199 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const compressSuffix = ".gz"
+
+// FileRetention is the policy how to handle the rotated backup files,
+// which is embedded into SizedRotatingFile and TimedRotatingFile.
+//
+// Notice: it should be set before writing the log into the file.
+type FileRetention struct {
+	// If true, compress the rotated backup files by gzip in a background
+	// goroutine, and the compressed file will be named with the suffix ".gz".
+	Compress bool
+
+	// If greater than 0, the backup files whose modification time is older
+	// than MaxAge will be removed.
+	MaxAge time.Duration
+
+	// If greater than 0, the oldest backup files will be removed until
+	// the total size of all the backup files is not greater than MaxTotalSize.
+	MaxTotalSize int64
+}
+
+// prune removes the backup files, which are sorted from oldest to newest,
+// by the retention policy, and returns the rest.
+func (r FileRetention) prune(files []backupFile, now time.Time) []backupFile {
+	if r.MaxAge > 0 {
+		deadline := now.Add(-r.MaxAge)
+		for len(files) > 0 && files[0].modTime.Before(deadline) {
+			os.Remove(files[0].name)
+			files = files[1:]
+		}
+	}
+
+	if r.MaxTotalSize > 0 {
+		var total int64
+		for i := len(files) - 1; i >= 0; i-- {
+			if total += files[i].size; total > r.MaxTotalSize {
+				for _, file := range files[:i+1] {
+					os.Remove(file.name)
+				}
+				files = files[i+1:]
+				break
+			}
+		}
+	}
+
+	return files
+}
+
+// compressBackups compresses the uncompressed backup files.
+//
+// lock is used to protect the backup files from being renamed or removed
+// by the rotating file, which is held only when replacing the original file
+// with the compressed one instead of during the whole compression.
+func (r FileRetention) compressBackups(files []backupFile, lock sync.Locker) {
+	if !r.Compress {
+		return
+	}
+
+	for _, file := range files {
+		if !strings.HasSuffix(file.name, compressSuffix) {
+			compressFile(file.name, lock)
+		}
+	}
+}
+
+// compressFile compresses the file by gzip into the file with the suffix
+// ".gz", then removes the original file.
+//
+// If the original file has been renamed or removed during the compression,
+// the compressed file will be discarded.
+//
+// The modification time of the compressed file is the same as the original.
+func compressFile(src string, lock sync.Locker) (err error) {
+	sf, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer sf.Close()
+
+	info, err := sf.Stat()
+	if err != nil {
+		return
+	}
+
+	dst := src + compressSuffix
+	tmp := dst + ".tmp"
+	df, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return
+	}
+
+	gw := gzip.NewWriter(df)
+	if _, err = io.Copy(gw, sf); err == nil {
+		err = gw.Close()
+	}
+	if err == nil {
+		err = df.Sync()
+	}
+	if e := df.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if cur, e := os.Stat(src); e != nil || !os.SameFile(info, cur) {
+		os.Remove(tmp)
+		return fmt.Errorf("the file '%s' has been changed", src)
+	}
+
+	if err = os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	os.Chtimes(dst, info.ModTime(), info.ModTime())
+	return os.Remove(src)
+}
+
+// backgroundTask is used to run the task to handle the backup files
+// in the background goroutine, and only one task runs at a time.
+//
+// The task submitted while another is running does not block the caller,
+// and only the last one of them will run after the running task finishes.
+type backgroundTask struct {
+	// files protects the backup files from being renamed or removed
+	// by the rotating file and the task at the same time.
+	files sync.Mutex
+
+	lock    sync.Mutex
+	pending func()
+	done    chan struct{}
+}
+
+// wait waits until the running and pending tasks finish.
+func (t *backgroundTask) wait() {
+	t.lock.Lock()
+	done := t.done
+	t.lock.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+func (t *backgroundTask) run(task func()) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.done != nil {
+		t.pending = task
+		return
+	}
+
+	t.done = make(chan struct{})
+	go t.loop(task, t.done)
+}
+
+func (t *backgroundTask) loop(task func(), done chan struct{}) {
+	defer close(done)
+	for task != nil {
+		task()
+
+		t.lock.Lock()
+		if task, t.pending = t.pending, nil; task == nil {
+			t.done = nil
+		}
+		t.lock.Unlock()
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func readGzipFile(t *testing.T, filename string) string {
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Error(err)
+	}
+	return string(data)
+}
+
+func TestSizedRotatingFileCompress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "test.log")
+	f, err := NewSizedRotatingFile(filename, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Compress = true
+
+	for _, s := range []string{"1\n", "2\n", "3\n", "4\n", "5\n", "6\n"} {
+		f.Write([]byte(s))
+	}
+	f.Close()
+
+	if s := readFile(t, filename); s != "5\n6\n" {
+		t.Error(s)
+	} else if s := readGzipFile(t, filename+".1.gz"); s != "3\n4\n" {
+		t.Error(s)
+	} else if s := readGzipFile(t, filename+".2.gz"); s != "1\n2\n" {
+		t.Error(s)
+	} else if fileIsExist(filename + ".1") {
+		t.Error("the uncompressed backup file is not removed")
+	}
+}
+
+func TestFileRetentionPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	var files []backupFile
+	for i, age := range []int{5, 4, 3, 2, 1} {
+		name := filepath.Join(dir, "test.log."+string(rune('5'-i)))
+		ioutil.WriteFile(name, []byte("12345"), 0644)
+		files = append(files, backupFile{name: name, size: 5,
+			modTime: now.Add(-time.Duration(age) * time.Hour)})
+	}
+
+	retention := FileRetention{MaxAge: time.Hour*3 + time.Minute, MaxTotalSize: 10}
+	files = retention.prune(files, now)
+	if len(files) != 2 {
+		t.Errorf("expected 2 backup files, but got %d", len(files))
+	} else if files[0].name != filepath.Join(dir, "test.log.2") {
+		t.Error(files[0].name)
+	}
+
+	for _, name := range []string{"test.log.5", "test.log.4", "test.log.3"} {
+		if fileIsExist(filepath.Join(dir, name)) {
+			t.Errorf("the backup file '%s' is not removed", name)
+		}
+	}
+}
+
+func TestSizedRotatingFileMixedBackups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "test.log")
+	ioutil.WriteFile(filename+".1", []byte("2\n"), 0644)
+	ioutil.WriteFile(filename+".2", []byte("1\n"), 0644)
+	compressFile(filename+".2", new(sync.Mutex))
+	ioutil.WriteFile(filename+".3", []byte("0\n"), 0644)
+	ioutil.WriteFile(filename+".3.gz", []byte("0\n"), 0644)
+
+	f, err := NewSizedRotatingFile(filename, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"3\n", "4\n", "5\n"} {
+		f.Write([]byte(s))
+	}
+	f.Close()
+
+	if s := readFile(t, filename); s != "5\n" {
+		t.Error(s)
+	} else if s := readFile(t, filename+".1"); s != "3\n4\n" {
+		t.Error(s)
+	} else if s := readFile(t, filename+".2"); s != "2\n" {
+		t.Error(s)
+	} else if s := readGzipFile(t, filename+".3.gz"); s != "1\n" {
+		t.Error(s)
+	}
+
+	for _, name := range []string{".1.gz", ".2.gz", ".3", ".4", ".4.gz"} {
+		if fileIsExist(filename + name) {
+			t.Errorf("unexpected the backup file '%s'", filename+name)
+		}
+	}
+}
+
+func TestBackgroundTask(t *testing.T) {
+	var task backgroundTask
+	var runs []int
+
+	start := make(chan struct{})
+	release := make(chan struct{})
+	task.run(func() { close(start); <-release; runs = append(runs, 1) })
+	<-start
+
+	// The tasks submitted during running neither block nor run concurrently,
+	// and only the last one runs after the running task.
+	task.run(func() { runs = append(runs, 2) })
+	task.run(func() { runs = append(runs, 3) })
+	close(release)
+	task.wait()
+
+	if len(runs) != 2 || runs[0] != 1 || runs[1] != 3 {
+		t.Errorf("unexpected the task runs: %v", runs)
+	}
+
+	task.run(func() { runs = append(runs, 4) })
+	task.wait()
+	if len(runs) != 3 || runs[2] != 4 {
+		t.Errorf("unexpected the task runs: %v", runs)
+	}
+}
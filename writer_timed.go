@@ -0,0 +1,544 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimedFileWriter is the same as FileWriter, but uses NewTimedRotatingFile
+// to generate the file writer, which rotates the file based on the time.
+//
+// filesize is parsed by ParseSize to get the maximum size of the log file.
+// If it is "", the file won't be rotated on the size.
+//
+// filenum is the number of the backup files to be kept. If it is 0 or
+// negative, all the backup files will be kept.
+//
+// Notice: the directory of the log file, which may contain the verbs
+// like filename, will be created automatically.
+func TimedFileWriter(filename string, interval time.Duration, filesize string,
+	filenum int) (Writer, error) {
+	var w io.WriteCloser = os.Stdout
+	if filename != "" {
+		size, err := ParseSize(filesize)
+		if err != nil {
+			return nil, err
+		}
+
+		if w, err = NewTimedRotatingFile(filename, interval, int(size), filenum); err != nil {
+			return nil, err
+		}
+	}
+
+	return StreamWriter(w), nil
+}
+
+// NewTimedRotatingFile returns a new TimedRotatingFile, which is thread-safe.
+//
+// filename may contain the strftime-like verbs as follow, and the log file
+// will be named by formatting it with the start time of the current period.
+//
+//   %Y  The year as a decimal number, such as 2020.
+//   %y  The year without century as a zero-padded decimal number, 00-99.
+//   %m  The month as a zero-padded decimal number, 01-12.
+//   %d  The day of the month as a zero-padded decimal number, 01-31.
+//   %j  The day of the year as a zero-padded decimal number, 001-366.
+//   %H  The hour (24-hour clock) as a zero-padded decimal number, 00-23.
+//   %M  The minute as a zero-padded decimal number, 00-59.
+//   %S  The second as a zero-padded decimal number, 00-59.
+//   %%  A literal '%' character.
+//
+// If filename does not contain any verb, the log is written into filename,
+// which will be renamed to "filename.SUFFIX" when rotating, and SUFFIX is
+// the start time of the period, such as "2006-01-02" for the daily rotation.
+//
+// interval is the period to rotate the file. If it is 0, it is inferred
+// from the finest verb in filename, or 24h by default.
+//
+// If size is greater than 0, the file will be also rotated when its size
+// exceeds it, and the backups in the same period are named "NAME.1",
+// "NAME.2", etc.
+//
+// count is the number of the backup files to be kept. If it is 0 or negative,
+// all the backup files will be kept.
+//
+// When restarting, it resumes the log file of the current period. And if
+// filename does not contain any verb, the log file left by the last process
+// will be renamed as the backup of its period by the modification time
+// if it belongs to the past period.
+//
+// If the directory of the log file, which may also contain the verbs,
+// does not exist, it will be created when opening or rotating the file.
+//
+// The default permission of the log file is 0644.
+func NewTimedRotatingFile(filename string, interval time.Duration, size, count int,
+	mode ...os.FileMode) (*TimedRotatingFile, error) {
+	var _mode os.FileMode = 0644
+	if len(mode) > 0 && mode[0] > 0 {
+		_mode = mode[0]
+	}
+
+	hasVerb, finest := parseStrftime(filename)
+	if interval <= 0 {
+		if interval = finest; interval <= 0 {
+			interval = time.Hour * 24
+		}
+	}
+
+	w := &TimedRotatingFile{
+		pattern:     filename,
+		hasVerb:     hasVerb,
+		filePerm:    _mode,
+		interval:    interval,
+		maxSize:     size,
+		backupCount: count,
+		now:         time.Now,
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// TimedRotatingFile is a file rotating logging writer based on the time,
+// which may be also rotated based on the size.
+type TimedRotatingFile struct {
+	FileRetention
+
+	file        *os.File
+	filePerm    os.FileMode
+	filename    string
+	pattern     string
+	hasVerb     bool
+	interval    time.Duration
+	maxSize     int
+	backupCount int
+	nbytes      int
+	task        backgroundTask
+	lock        sync.Mutex
+
+	start time.Time
+	next  time.Time
+	now   func() time.Time
+}
+
+// Filename returns the name of the current log file.
+func (f *TimedRotatingFile) Filename() string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.filename
+}
+
+// Close implements io.Closer, which will wait until the background task
+// handling the backup files finishes.
+func (f *TimedRotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.task.wait()
+	return f.close()
+}
+
+// Flush flushes the data to the underlying disk.
+func (f *TimedRotatingFile) Flush() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.file.Sync()
+}
+
+// Reopen reopens the current log file, which is used to cooperate with
+// the external log rotation tool, such as logrotate. If failing,
+// the original file is still used.
+func (f *TimedRotatingFile) Reopen() (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return errors.New("the file has been closed")
+	}
+
+	old := f.file
+	if err = f.openFile(); err != nil {
+		return
+	}
+	return old.Close()
+}
+
+// Write implements io.Writer.
+func (f *TimedRotatingFile) Write(data []byte) (n int, err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return 0, errors.New("the file has been closed")
+	}
+
+	if now := f.now(); !now.Before(f.next) {
+		if err = f.doTimedRollover(now); err != nil {
+			return
+		}
+	} else if f.maxSize > 0 && f.nbytes+len(data) > f.maxSize {
+		if err = f.doSizedRollover(); err != nil {
+			return
+		}
+	}
+
+	if n, err = f.file.Write(data); err != nil {
+		return
+	}
+
+	f.nbytes += n
+	return
+}
+
+func (f *TimedRotatingFile) periodStart(t time.Time) time.Time {
+	_, offset := t.Zone()
+	zone := time.Duration(offset) * time.Second
+	return t.Add(zone).Truncate(f.interval).Add(-zone)
+}
+
+func (f *TimedRotatingFile) setPeriod(now time.Time) {
+	f.start = f.periodStart(now)
+	f.next = f.start.Add(f.interval)
+	if f.hasVerb {
+		f.filename = string(strftime(nil, f.pattern, f.start))
+	} else {
+		f.filename = f.pattern
+	}
+}
+
+func (f *TimedRotatingFile) backupSuffix(start time.Time) string {
+	switch {
+	case f.interval >= time.Hour*24:
+		return start.Format("2006-01-02")
+	case f.interval >= time.Hour:
+		return start.Format("2006-01-02_15")
+	case f.interval >= time.Minute:
+		return start.Format("2006-01-02_15-04")
+	default:
+		return start.Format("2006-01-02_15-04-05")
+	}
+}
+
+func (f *TimedRotatingFile) open() (err error) {
+	now := f.now()
+	f.setPeriod(now)
+
+	// Rotate the log file left by the last process, which belongs to
+	// the past period.
+	if !f.hasVerb {
+		if info, err := os.Stat(f.filename); err == nil && info.Size() > 0 {
+			if start := f.periodStart(info.ModTime()); start.Before(f.start) {
+				if err = f.renameBackup(f.pattern+"."+f.backupSuffix(start), false); err != nil {
+					return err
+				}
+				f.handleBackups()
+			}
+		}
+	}
+
+	f.mkdir()
+	return f.openFile()
+}
+
+// mkdir creates the directory of the current log file, which may be changed
+// with the period if the pattern contains the verbs.
+func (f *TimedRotatingFile) mkdir() {
+	os.MkdirAll(filepath.Dir(f.filename), 0755)
+}
+
+func (f *TimedRotatingFile) openFile() (err error) {
+	file, err := os.OpenFile(f.filename, fileFlag, f.filePerm)
+	if err != nil {
+		return
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	f.nbytes = int(info.Size())
+	f.file = file
+	return
+}
+
+func (f *TimedRotatingFile) close() (err error) {
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	return
+}
+
+// renameBackup renames the current log file to the backup file named dst.
+//
+// If dst has existed or numbered is true, the backup file will be renamed
+// to "dst.N" instead, and N is the minimum number that does not exist.
+func (f *TimedRotatingFile) renameBackup(dst string, numbered bool) (err error) {
+	if !fileIsExist(f.filename) {
+		return nil
+	} else if n, err := fileSize(f.filename); err != nil {
+		return fmt.Errorf("failed to get the size of the rotating file '%s': %s",
+			f.filename, err)
+	} else if n == 0 {
+		return nil
+	}
+
+	if numbered || backupIsExist(dst) {
+		for i := 1; ; i++ {
+			if name := dst + "." + strconv.Itoa(i); !backupIsExist(name) {
+				dst = name
+				break
+			}
+		}
+	}
+
+	if err = os.Rename(f.filename, dst); err != nil {
+		return fmt.Errorf("failed to rename the rotating file '%s' to '%s': %s",
+			f.filename, dst, err)
+	}
+	return
+}
+
+func (f *TimedRotatingFile) doTimedRollover(now time.Time) (err error) {
+	// Prevent the backup files from being handled by the background task.
+	f.task.files.Lock()
+	defer f.task.files.Unlock()
+
+	if err = f.close(); err != nil {
+		return fmt.Errorf("failed to close the rotating file '%s': %s", f.filename, err)
+	}
+
+	if !f.hasVerb {
+		err = f.renameBackup(f.pattern+"."+f.backupSuffix(f.start), false)
+		if err != nil {
+			return
+		}
+	}
+
+	f.setPeriod(now)
+	f.mkdir()
+	if err = f.openFile(); err == nil {
+		f.handleBackups()
+	}
+	return
+}
+
+func (f *TimedRotatingFile) doSizedRollover() (err error) {
+	// Prevent the backup files from being handled by the background task.
+	f.task.files.Lock()
+	defer f.task.files.Unlock()
+
+	if err = f.close(); err != nil {
+		return fmt.Errorf("failed to close the rotating file '%s': %s", f.filename, err)
+	}
+
+	dst := f.filename
+	if !f.hasVerb {
+		dst = f.pattern + "." + f.backupSuffix(f.start)
+	}
+
+	if err = f.renameBackup(dst, f.hasVerb); err != nil {
+		return
+	} else if err = f.openFile(); err == nil {
+		f.handleBackups()
+	}
+	return
+}
+
+type backupFile struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+type backupFiles []backupFile
+
+func (fs backupFiles) Len() int           { return len(fs) }
+func (fs backupFiles) Swap(i, j int)      { fs[i], fs[j] = fs[j], fs[i] }
+func (fs backupFiles) Less(i, j int) bool { return fs[i].modTime.Before(fs[j].modTime) }
+
+// backups returns all the backup files sorted by the modification time
+// from oldest to newest.
+func (f *TimedRotatingFile) backups() (files []backupFile) {
+	var patterns []string
+	if f.hasVerb {
+		glob := strftimeGlob(f.pattern)
+		patterns = []string{glob, glob + ".*"}
+	} else {
+		patterns = []string{f.pattern + ".*"}
+	}
+
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, name := range matches {
+			if name == f.filename || strings.HasSuffix(name, ".tmp") {
+				continue
+			}
+
+			if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+				files = append(files, backupFile{
+					name:    name,
+					size:    info.Size(),
+					modTime: info.ModTime(),
+				})
+			}
+		}
+	}
+
+	sort.Stable(backupFiles(files))
+	return
+}
+
+// handleBackups compresses and prunes the backup files in the background.
+func (f *TimedRotatingFile) handleBackups() {
+	if f.backupCount <= 0 && !f.Compress && f.MaxAge <= 0 && f.MaxTotalSize <= 0 {
+		return
+	}
+
+	now := f.now()
+	retention := f.FileRetention
+	f.task.run(func() {
+		f.task.files.Lock()
+		files := f.backups()
+		f.task.files.Unlock()
+		retention.compressBackups(files, &f.task.files)
+
+		f.task.files.Lock()
+		defer f.task.files.Unlock()
+
+		files = f.backups()
+		if f.backupCount > 0 && len(files) > f.backupCount {
+			for _, file := range files[:len(files)-f.backupCount] {
+				os.Remove(file.name)
+			}
+			files = files[len(files)-f.backupCount:]
+		}
+		retention.prune(files, now)
+	})
+}
+
+func backupIsExist(name string) bool {
+	return fileIsExist(name) || fileIsExist(name+compressSuffix)
+}
+
+//////////////////////////////////////////////////////////////////////////////
+
+// parseStrftime reports whether the layout contains the strftime-like verbs,
+// and returns the interval of the finest verb.
+func parseStrftime(layout string) (hasVerb bool, finest time.Duration) {
+	for i, _len := 0, len(layout)-1; i < _len; i++ {
+		if layout[i] != '%' {
+			continue
+		}
+
+		var interval time.Duration
+		switch i++; layout[i] {
+		case 'S':
+			interval = time.Second
+		case 'M':
+			interval = time.Minute
+		case 'H':
+			interval = time.Hour
+		case 'Y', 'y', 'm', 'd', 'j':
+			interval = time.Hour * 24
+		default:
+			continue
+		}
+
+		hasVerb = true
+		if finest == 0 || interval < finest {
+			finest = interval
+		}
+	}
+	return
+}
+
+// strftime appends the time formatted by the strftime-like layout into buf.
+func strftime(buf []byte, layout string, t time.Time) []byte {
+	for i, _len := 0, len(layout); i < _len; i++ {
+		if c := layout[i]; c != '%' || i == _len-1 {
+			buf = append(buf, c)
+			continue
+		}
+
+		switch i++; layout[i] {
+		case 'Y':
+			buf = appendPadInt(buf, t.Year(), 4)
+		case 'y':
+			buf = appendPadInt(buf, t.Year()%100, 2)
+		case 'm':
+			buf = appendPadInt(buf, int(t.Month()), 2)
+		case 'd':
+			buf = appendPadInt(buf, t.Day(), 2)
+		case 'j':
+			buf = appendPadInt(buf, t.YearDay(), 3)
+		case 'H':
+			buf = appendPadInt(buf, t.Hour(), 2)
+		case 'M':
+			buf = appendPadInt(buf, t.Minute(), 2)
+		case 'S':
+			buf = appendPadInt(buf, t.Second(), 2)
+		case '%':
+			buf = append(buf, '%')
+		default:
+			buf = append(buf, '%', layout[i])
+		}
+	}
+	return buf
+}
+
+// strftimeGlob converts the strftime-like layout to the glob pattern
+// by replacing the verbs with "*".
+func strftimeGlob(layout string) string {
+	b := make([]byte, 0, len(layout))
+	for i, _len := 0, len(layout); i < _len; i++ {
+		if c := layout[i]; c != '%' || i == _len-1 {
+			b = append(b, c)
+			continue
+		}
+
+		switch i++; layout[i] {
+		case 'Y', 'y', 'm', 'd', 'j', 'H', 'M', 'S':
+			b = append(b, '*')
+		case '%':
+			b = append(b, '%')
+		default:
+			b = append(b, '%', layout[i])
+		}
+	}
+	return string(b)
+}
+
+func appendPadInt(buf []byte, v, width int) []byte {
+	digits := 1
+	for i := v; i >= 10; i /= 10 {
+		digits++
+	}
+	for ; digits < width; digits++ {
+		buf = append(buf, '0')
+	}
+	return strconv.AppendInt(buf, int64(v), 10)
+}
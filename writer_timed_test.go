@@ -0,0 +1,189 @@
+// Copyright 2020 xgfone
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package klog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStrftime(t *testing.T) {
+	now := time.Date(2020, 3, 4, 5, 6, 7, 0, time.Local)
+	if s := string(strftime(nil, "app-%Y%m%d_%H%M%S.%j.%y%%.log", now)); s != "app-20200304_050607.064.20%.log" {
+		t.Error(s)
+	}
+
+	if s := strftimeGlob("app-%Y%m%d.log"); s != "app-***.log" {
+		t.Error(s)
+	}
+
+	if ok, finest := parseStrftime("app-%Y%m%d.log"); !ok || finest != time.Hour*24 {
+		t.Error(ok, finest)
+	} else if ok, finest = parseStrftime("app.log"); ok || finest != 0 {
+		t.Error(ok, finest)
+	}
+}
+
+func readFile(t *testing.T, filename string) string {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Error(err)
+	}
+	return string(data)
+}
+
+func TestTimedRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "test.log")
+	now := time.Date(2020, 3, 4, 5, 6, 7, 0, time.Local)
+	f, err := NewTimedRotatingFile(filename, time.Hour, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.now = func() time.Time { return now }
+	f.setPeriod(now)
+
+	f.Write([]byte("1\n"))
+	now = now.Add(time.Hour)
+	f.Write([]byte("2\n"))
+	now = now.Add(time.Hour)
+	f.Write([]byte("3\n"))
+	f.task.wait()
+
+	if s := readFile(t, filename); s != "3\n" {
+		t.Error(s)
+	} else if s := readFile(t, filename+".2020-03-04_06"); s != "2\n" {
+		t.Error(s)
+	} else if fileIsExist(filename + ".2020-03-04_05") {
+		t.Error("the oldest backup file is not removed")
+	}
+}
+
+func TestTimedRotatingFilePattern(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 3, 4, 5, 6, 7, 0, time.Local)
+	pattern := filepath.Join(dir, "test-%Y%m%d.log")
+	f, err := NewTimedRotatingFile(pattern, 0, 4, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	f.now = func() time.Time { return now }
+	f.setPeriod(now)
+	f.close()
+	f.openFile()
+
+	f.Write([]byte("1\n"))
+	f.Write([]byte("2\n"))
+	f.Write([]byte("3\n"))
+	now = now.AddDate(0, 0, 1)
+	f.Write([]byte("4\n"))
+
+	if s := readFile(t, filepath.Join(dir, "test-20200304.log.1")); s != "1\n2\n" {
+		t.Error(s)
+	} else if s := readFile(t, filepath.Join(dir, "test-20200304.log")); s != "3\n" {
+		t.Error(s)
+	} else if s := readFile(t, filepath.Join(dir, "test-20200305.log")); s != "4\n" {
+		t.Error(s)
+	}
+}
+
+func TestTimedRotatingFileRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The file left by the last process in the past period.
+	filename := filepath.Join(dir, "test.log")
+	old := time.Now().AddDate(0, 0, -2)
+	ioutil.WriteFile(filename, []byte("1\n"), 0644)
+	os.Chtimes(filename, old, old)
+
+	f, err := NewTimedRotatingFile(filename, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("2\n"))
+	f.Close()
+
+	backup := filename + "." + old.Format("2006-01-02")
+	if s := readFile(t, backup); s != "1\n" {
+		t.Errorf("%s: %s", backup, s)
+	} else if s := readFile(t, filename); s != "2\n" {
+		t.Errorf("%s: %s", filename, s)
+	}
+
+	// Resume the file in the current period.
+	if f, err = NewTimedRotatingFile(filename, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("3\n"))
+	f.Close()
+
+	if s := readFile(t, filename); s != "2\n3\n" {
+		t.Errorf("%s: %s", filename, s)
+	} else if files := f.backups(); len(files) != 1 || files[0].name != backup {
+		t.Errorf("unexpected the backups: %v", files)
+	}
+}
+
+func TestTimedFileWriterDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "klog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := TimedFileWriter(filepath.Join(dir, "%Y", "test-%m%d.log"), 0, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if fileIsExist(filepath.Join(dir, "%Y")) {
+		t.Error("unexpected the directory '%Y'")
+	} else if !fileIsExist(filepath.Join(dir, time.Now().Format("2006"))) {
+		t.Error("the directory of the current year is not created")
+	}
+
+	// The directory of the new period is created when rotating.
+	f := w.(streamWriter).Writer.(*TimedRotatingFile)
+	now := time.Date(time.Now().Year()+1, 1, 1, 5, 6, 7, 0, time.Local)
+	f.now = func() time.Time { return now }
+	f.Write([]byte("1\n"))
+
+	filename := filepath.Join(dir, now.Format("2006"), "test-0101.log")
+	if s := readFile(t, filename); s != "1\n" {
+		t.Error(s)
+	}
+}